@@ -3,26 +3,26 @@ module github.com/go-corelibs/replace
 go 1.21.5
 
 require (
+	github.com/gabriel-vasile/mimetype v1.4.3
 	github.com/go-corelibs/diff v1.1.1
 	github.com/go-corelibs/globs v1.0.0
 	github.com/go-corelibs/maps v1.1.0
 	github.com/go-corelibs/path v1.2.0
 	github.com/go-corelibs/strcases v1.0.0
+	github.com/maruel/natural v1.1.1
 	github.com/smartystreets/goconvey v1.8.1
+	golang.org/x/sys v0.13.0
 )
 
 require (
 	github.com/djherbis/times v1.6.0 // indirect
-	github.com/gabriel-vasile/mimetype v1.4.3 // indirect
 	github.com/ganbarodigital/go_glob v1.0.0 // indirect
 	github.com/go-corelibs/maths v1.0.1 // indirect
 	github.com/gopherjs/gopherjs v1.17.2 // indirect
 	github.com/hexops/gotextdiff v1.0.3 // indirect
 	github.com/iancoleman/strcase v0.3.0 // indirect
 	github.com/jtolds/gls v4.20.0+incompatible // indirect
-	github.com/maruel/natural v1.1.1 // indirect
 	github.com/sergi/go-diff v1.3.1 // indirect
 	github.com/smarty/assertions v1.15.0 // indirect
 	golang.org/x/net v0.17.0 // indirect
-	golang.org/x/sys v0.13.0 // indirect
 )
@@ -0,0 +1,49 @@
+// Copyright (c) 2024  The Go-Curses Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package replace
+
+import (
+	"io"
+
+	"github.com/go-corelibs/diff"
+	"github.com/go-corelibs/globs"
+)
+
+// StringArchive uses String to ProcessArchive
+func StringArchive(search, replace, target string, w io.Writer, include, exclude globs.Globs) (deltas []*diff.Diff, count int, err error) {
+	deltas, count, err = ProcessArchive(target, w, include, exclude, func(original string) (modified string, count int) {
+		modified, count = String(search, replace, original)
+		return
+	})
+	return
+}
+
+// StringInsensitiveArchive uses StringInsensitive to ProcessArchive
+func StringInsensitiveArchive(search, replace, target string, w io.Writer, include, exclude globs.Globs) (deltas []*diff.Diff, count int, err error) {
+	deltas, count, err = ProcessArchive(target, w, include, exclude, func(original string) (modified string, count int) {
+		modified, count = StringInsensitive(search, replace, original)
+		return
+	})
+	return
+}
+
+// StringPreserveArchive uses StringPreserve to ProcessArchive
+func StringPreserveArchive(search, replace, target string, w io.Writer, include, exclude globs.Globs) (deltas []*diff.Diff, count int, err error) {
+	deltas, count, err = ProcessArchive(target, w, include, exclude, func(original string) (modified string, count int) {
+		modified, count = StringPreserve(search, replace, original)
+		return
+	})
+	return
+}
@@ -0,0 +1,53 @@
+// Copyright (c) 2024  The Go-Curses Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package replace
+
+import (
+	"bytes"
+	"path/filepath"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestStringArchive(t *testing.T) {
+	dir := tMakeArchives(t)
+	target := filepath.Join(dir, "release.tar")
+
+	Convey("StringArchive", t, func() {
+		var buffer bytes.Buffer
+		deltas, count, err := StringArchive("DB.internal", "db.public", target, &buffer, nil, nil)
+		So(err, ShouldBeNil)
+		So(count, ShouldEqual, 0)
+		So(len(deltas), ShouldEqual, 0)
+		So(buffer.Len(), ShouldBeGreaterThan, 0)
+	})
+
+	Convey("StringInsensitiveArchive", t, func() {
+		var buffer bytes.Buffer
+		deltas, count, err := StringInsensitiveArchive("DB.internal", "db.public", target, &buffer, nil, nil)
+		So(err, ShouldBeNil)
+		So(count, ShouldEqual, 2)
+		So(len(deltas), ShouldEqual, 2)
+	})
+
+	Convey("StringPreserveArchive", t, func() {
+		var buffer bytes.Buffer
+		deltas, count, err := StringPreserveArchive("internal", "public", target, &buffer, nil, nil)
+		So(err, ShouldBeNil)
+		So(count, ShouldEqual, 2)
+		So(len(deltas), ShouldEqual, 2)
+	})
+}
@@ -0,0 +1,476 @@
+// Copyright (c) 2024  The Go-Curses Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package replace
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/go-corelibs/diff"
+	"github.com/go-corelibs/globs"
+)
+
+var (
+	ErrNotArchive = errors.New("not an archive")
+)
+
+// ArchiveSeparator is the string used to join an archive file path with the
+// path of a member within that archive, for example:
+//
+//	release.tar.gz!/etc/hosts
+const ArchiveSeparator = "!/"
+
+type archiveKind uint8
+
+const (
+	archiveNone archiveKind = iota
+	archiveZip
+	archiveTar
+	archiveTarGz
+	archiveGz
+)
+
+func detectArchive(target string) (kind archiveKind) {
+	name := strings.ToLower(target)
+	switch {
+	case strings.HasSuffix(name, ".zip"), strings.HasSuffix(name, ".jar"):
+		kind = archiveZip
+	case strings.HasSuffix(name, ".tar"):
+		kind = archiveTar
+	case strings.HasSuffix(name, ".tar.gz"), strings.HasSuffix(name, ".tgz"):
+		kind = archiveTarGz
+	case strings.HasSuffix(name, ".gz"):
+		kind = archiveGz
+	}
+	return
+}
+
+// IsArchive returns true if the given `target` has one of the supported
+// archive file extensions: .zip, .jar, .tar, .tar.gz, .tgz or .gz
+func IsArchive(target string) (archive bool) {
+	archive = detectArchive(target) != archiveNone
+	return
+}
+
+// JoinArchivePath returns the `archive` and `member` paths joined with the
+// ArchiveSeparator
+func JoinArchivePath(archive, member string) (joined string) {
+	joined = archive + ArchiveSeparator + strings.TrimPrefix(member, "/")
+	return
+}
+
+// SplitArchivePath is the inverse of JoinArchivePath, `ok` is false if the
+// `input` does not contain the ArchiveSeparator
+func SplitArchivePath(input string) (archive, member string, ok bool) {
+	archive, member, ok = strings.Cut(input, ArchiveSeparator)
+	return
+}
+
+// archiveEntry is one regular file within an archive, `read` is only valid
+// for the duration of the walkArchive callback
+type archiveEntry struct {
+	name string
+	size int64
+	read func() (data []byte, err error)
+}
+
+// walkArchive calls `fn` with each regular file found within the `target`
+// archive, in the order stored, stopping early if `fn` returns true. Reading
+// an entry fails with ErrLargeFile once more than `limit` bytes have been
+// decompressed, zero or less is unlimited
+func walkArchive(w walker, target string, limit int64, fn func(entry archiveEntry) (stop bool)) (err error) {
+	kind := detectArchive(target)
+	if kind == archiveNone {
+		err = ErrNotArchive
+		return
+	}
+
+	var fh fs.File
+	if fh, err = w.open(target); err != nil {
+		return
+	}
+	defer fh.Close()
+
+	switch kind {
+
+	case archiveZip:
+		var info fs.FileInfo
+		if info, err = fh.Stat(); err != nil {
+			return
+		}
+		ra, ok := fh.(io.ReaderAt)
+		if !ok {
+			// zip needs random access
+			var data []byte
+			if data, err = io.ReadAll(fh); err != nil {
+				return
+			}
+			ra = bytes.NewReader(data)
+		}
+		var zr *zip.Reader
+		if zr, err = zip.NewReader(ra, info.Size()); err != nil {
+			return
+		}
+		for _, zf := range zr.File {
+			if !zf.Mode().IsRegular() {
+				continue
+			}
+			zf := zf
+			if fn(archiveEntry{
+				name: zf.Name,
+				size: int64(zf.UncompressedSize64),
+				read: func() (data []byte, err error) {
+					var rc io.ReadCloser
+					if rc, err = zf.Open(); err == nil {
+						defer rc.Close()
+						// the uncompressed size is only what the archive claims
+						data, err = readLimited(rc, limit)
+					}
+					return
+				},
+			}) {
+				return
+			}
+		}
+
+	case archiveTar, archiveTarGz, archiveGz:
+		var r io.Reader = fh
+		if kind != archiveTar {
+			var gz *gzip.Reader
+			if gz, err = gzip.NewReader(fh); err != nil {
+				return
+			}
+			defer gz.Close()
+			r = gz
+			if kind == archiveGz {
+				fn(archiveEntry{
+					name: gzipMemberName(gz, target),
+					size: -1, // unknown until decompressed
+					read: func() (data []byte, err error) {
+						data, err = readLimited(gz, limit)
+						return
+					},
+				})
+				return
+			}
+		}
+		tr := tar.NewReader(r)
+		for {
+			var hdr *tar.Header
+			if hdr, err = tr.Next(); errors.Is(err, io.EOF) {
+				err = nil
+				return
+			} else if err != nil {
+				return
+			} else if hdr.Typeflag != tar.TypeReg {
+				continue
+			}
+			if fn(archiveEntry{
+				name: hdr.Name,
+				size: hdr.Size,
+				read: func() (data []byte, err error) {
+					data, err = readLimited(tr, limit)
+					return
+				},
+			}) {
+				return
+			}
+		}
+	}
+	return
+}
+
+// isHiddenMember returns true if any of the slash-separated segments of the
+// archive member `name` start with a period, ignoring "." and ".." segments
+func isHiddenMember(name string) (hidden bool) {
+	for _, segment := range strings.Split(name, "/") {
+		if hidden = len(segment) > 0 && segment[0] == '.' && segment != "." && segment != ".."; hidden {
+			return
+		}
+	}
+	return
+}
+
+// readLimited reads all of `r`, failing with ErrLargeFile as soon as more than
+// `limit` bytes are read, zero or less is unlimited
+func readLimited(r io.Reader, limit int64) (data []byte, err error) {
+	if limit <= 0 {
+		data, err = io.ReadAll(r)
+		return
+	}
+	if data, err = io.ReadAll(io.LimitReader(r, limit+1)); err == nil && int64(len(data)) > limit {
+		data, err = nil, ErrLargeFile
+	}
+	return
+}
+
+// gzipMemberName returns the original file name stored in the gzip header,
+// or the `target` base name without the .gz extension
+func gzipMemberName(gr *gzip.Reader, target string) (name string) {
+	if name = gr.Name; name == "" {
+		name = strings.TrimSuffix(filepath.Base(target), filepath.Ext(target))
+	}
+	return
+}
+
+// isTextData returns true if the given `data` is not detected as binary by
+// the DefaultBinaryDetector
+func isTextData(data []byte) (text bool) {
+	text = !DefaultBinaryDetector.IsBinary("", data[:min(len(data), DefaultBinaryDetector.SampleSize())])
+	return
+}
+
+// ProcessArchive reads the `target` archive and writes a new archive of the
+// same format to `w`, running the given `fn` with the decoded text of each
+// regular member that IsIncluded. Binary members, members larger than the
+// MaxFileSize and members without changes keep their original contents, order,
+// modes and timestamps. Zip members are copied byte for byte, tar headers are
+// re-encoded by archive/tar (which may choose a different USTAR, PAX or GNU
+// format than the original) and gzip streams are always recompressed. The
+// `deltas` returned
+// have one Diff for each modified member, using JoinArchivePath for naming
+// the member
+func ProcessArchive(target string, w io.Writer, include, exclude globs.Globs, fn func(original string) (modified string, count int)) (deltas []*diff.Diff, count int, err error) {
+	p := &cArchiveProcessor{target: target, include: include, exclude: exclude, fn: fn}
+
+	switch kind := detectArchive(target); kind {
+
+	case archiveZip:
+		err = p.processZip(w)
+
+	case archiveTar, archiveTarGz, archiveGz:
+		var fh *os.File
+		if fh, err = os.Open(target); err != nil {
+			return
+		}
+		defer fh.Close()
+		if kind == archiveTar {
+			err = p.processTar(fh, w)
+			break
+		}
+
+		var gr *gzip.Reader
+		if gr, err = gzip.NewReader(fh); err != nil {
+			return
+		}
+		defer gr.Close()
+		gw := gzip.NewWriter(w)
+		gw.Header = gr.Header
+		if kind == archiveTarGz {
+			err = p.processTar(gr, gw)
+		} else {
+			err = p.processGz(gr, gw)
+		}
+		if ee := gw.Close(); err == nil {
+			err = ee
+		}
+
+	default:
+		err = ErrNotArchive
+	}
+
+	deltas, count = p.deltas, p.count
+	return
+}
+
+type cArchiveProcessor struct {
+	target  string
+	include globs.Globs
+	exclude globs.Globs
+	fn      func(original string) (modified string, count int)
+	deltas  []*diff.Diff
+	count   int
+}
+
+// process returns the given `data` with `fn` applied, `changed` is false when
+// `output` is the unmodified `data`
+func (p *cArchiveProcessor) process(name string, data []byte) (output []byte, changed bool, err error) {
+	output = data
+	if !IsIncluded(p.include, p.exclude, name) || !isTextData(data) {
+		return
+	}
+	var enc Encoding
+	var original string
+	if original, enc, err = DecodeText(data); err != nil {
+		// not text after all
+		err = nil
+		return
+	}
+	modified, count := p.fn(original)
+	if count == 0 || modified == original {
+		return
+	} else if output, err = enc.Encode(modified); err != nil {
+		err = fmt.Errorf("%s: %w", JoinArchivePath(p.target, name), err)
+		return
+	}
+	changed = true
+	p.count += count
+	p.deltas = append(p.deltas, diff.New(JoinArchivePath(p.target, name), original, modified))
+	return
+}
+
+func (p *cArchiveProcessor) processZip(w io.Writer) (err error) {
+	var zr *zip.ReadCloser
+	if zr, err = zip.OpenReader(p.target); err != nil {
+		return
+	}
+	defer zr.Close()
+
+	zw := zip.NewWriter(w)
+	for _, zf := range zr.File {
+		if !zf.Mode().IsRegular() || zf.UncompressedSize64 > uint64(MaxFileSize) {
+			if err = zw.Copy(zf); err != nil {
+				return
+			}
+			continue
+		}
+
+		var rc io.ReadCloser
+		var data, output []byte
+		var changed bool
+		if rc, err = zf.Open(); err != nil {
+			return
+		}
+		data, err = io.ReadAll(io.LimitReader(rc, max(MaxFileSize, 0)+1))
+		_ = rc.Close()
+		if err != nil {
+			return
+		} else if int64(len(data)) > MaxFileSize {
+			// the header understated the size, leave it be
+			if err = zw.Copy(zf); err != nil {
+				return
+			}
+			continue
+		} else if output, changed, err = p.process(zf.Name, data); err != nil {
+			return
+		}
+
+		if !changed {
+			// copy the original compressed bytes
+			err = zw.Copy(zf)
+		} else {
+			hdr := zf.FileHeader
+			hdr.CRC32 = 0
+			hdr.CompressedSize, hdr.CompressedSize64 = 0, 0
+			hdr.UncompressedSize, hdr.UncompressedSize64 = 0, 0
+			// zip.Writer adds these itself
+			hdr.Extra = stripZipExtra(hdr.Extra, zipExtraTimestamp, zipExtraZip64)
+			var fw io.Writer
+			if fw, err = zw.CreateHeader(&hdr); err == nil {
+				_, err = fw.Write(output)
+			}
+		}
+		if err != nil {
+			return
+		}
+	}
+
+	if err = zw.SetComment(zr.Comment); err == nil {
+		err = zw.Close()
+	}
+	return
+}
+
+const (
+	zipExtraZip64     = 0x0001
+	zipExtraTimestamp = 0x5455
+)
+
+// stripZipExtra returns the zip `extra` field data without any of the records
+// with the `ids` given, anything that can't be parsed is kept as-is
+func stripZipExtra(extra []byte, ids ...uint16) (stripped []byte) {
+	for len(extra) >= 4 {
+		id := binary.LittleEndian.Uint16(extra[0:2])
+		size := 4 + int(binary.LittleEndian.Uint16(extra[2:4]))
+		if size > len(extra) {
+			break
+		}
+		if !slices.Contains(ids, id) {
+			stripped = append(stripped, extra[:size]...)
+		}
+		extra = extra[size:]
+	}
+	stripped = append(stripped, extra...)
+	return
+}
+
+func (p *cArchiveProcessor) processTar(r io.Reader, w io.Writer) (err error) {
+	tr := tar.NewReader(r)
+	tw := tar.NewWriter(w)
+	for {
+		var hdr *tar.Header
+		if hdr, err = tr.Next(); errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return
+		}
+
+		if hdr.Typeflag != tar.TypeReg || hdr.Size > MaxFileSize {
+			if err = tw.WriteHeader(hdr); err == nil {
+				_, err = io.Copy(tw, tr)
+			}
+			if err != nil {
+				return
+			}
+			continue
+		}
+
+		var data, output []byte
+		var changed bool
+		if data, err = io.ReadAll(tr); err != nil {
+			return
+		} else if output, changed, err = p.process(hdr.Name, data); err != nil {
+			return
+		} else if changed {
+			hdr.Size = int64(len(output))
+		}
+		if err = tw.WriteHeader(hdr); err == nil {
+			_, err = tw.Write(output)
+		}
+		if err != nil {
+			return
+		}
+	}
+	err = tw.Close()
+	return
+}
+
+func (p *cArchiveProcessor) processGz(gr *gzip.Reader, w io.Writer) (err error) {
+	var data, output []byte
+	if data, err = io.ReadAll(io.LimitReader(gr, max(MaxFileSize, 0)+1)); err != nil {
+		return
+	} else if int64(len(data)) > MaxFileSize {
+		// too large to process, stream it through unchanged
+		if _, err = w.Write(data); err == nil {
+			_, err = io.Copy(w, gr)
+		}
+		return
+	} else if output, _, err = p.process(gzipMemberName(gr, p.target), data); err != nil {
+		return
+	}
+	_, err = w.Write(output)
+	return
+}
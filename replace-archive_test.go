@@ -0,0 +1,345 @@
+// Copyright (c) 2024  The Go-Curses Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package replace
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/go-corelibs/globs"
+)
+
+type tArchiveMember struct {
+	name string
+	body string
+}
+
+var (
+	tArchiveMembers = []tArchiveMember{
+		{"docs/hosts.txt", "db.internal.example.com\n"},
+		{"docs/.hidden", "db.internal.example.com\n"},
+		{"bin/tool", "\x7fELF\x00\x00\x00db.internal.example.com"},
+		{"README.md", "nothing to see here\n"},
+	}
+	tArchiveModTime = time.Date(2024, 1, 29, 12, 0, 0, 0, time.UTC)
+)
+
+func tMakeTar(members []tArchiveMember) (data []byte) {
+	var buffer bytes.Buffer
+	tw := tar.NewWriter(&buffer)
+	for _, m := range members {
+		_ = tw.WriteHeader(&tar.Header{
+			Typeflag: tar.TypeReg,
+			Name:     m.name,
+			Mode:     0640,
+			Size:     int64(len(m.body)),
+			ModTime:  tArchiveModTime,
+		})
+		_, _ = tw.Write([]byte(m.body))
+	}
+	_ = tw.Close()
+	data = buffer.Bytes()
+	return
+}
+
+func tMakeGzip(name string, data []byte) (compressed []byte) {
+	var buffer bytes.Buffer
+	gw := gzip.NewWriter(&buffer)
+	gw.Name = name
+	_, _ = gw.Write(data)
+	_ = gw.Close()
+	compressed = buffer.Bytes()
+	return
+}
+
+func tMakeZip(members []tArchiveMember) (data []byte) {
+	var buffer bytes.Buffer
+	zw := zip.NewWriter(&buffer)
+	for _, m := range members {
+		hdr := &zip.FileHeader{Name: m.name, Method: zip.Deflate, Modified: tArchiveModTime}
+		hdr.SetMode(0640)
+		w, _ := zw.CreateHeader(hdr)
+		_, _ = w.Write([]byte(m.body))
+	}
+	_ = zw.Close()
+	data = buffer.Bytes()
+	return
+}
+
+func tMakeArchives(t *testing.T) (dir string) {
+	dir = t.TempDir()
+	_ = os.WriteFile(filepath.Join(dir, "release.tar"), tMakeTar(tArchiveMembers), 0644)
+	_ = os.WriteFile(filepath.Join(dir, "release.tar.gz"), tMakeGzip("", tMakeTar(tArchiveMembers)), 0644)
+	_ = os.WriteFile(filepath.Join(dir, "release.jar"), tMakeZip(tArchiveMembers), 0644)
+	_ = os.WriteFile(filepath.Join(dir, "hosts.txt.gz"), tMakeGzip("", []byte(tArchiveMembers[0].body)), 0644)
+	return
+}
+
+func TestArchive(t *testing.T) {
+
+	Convey("archive paths", t, func() {
+		So(IsArchive("thing.tar.gz"), ShouldBeTrue)
+		So(IsArchive("THING.JAR"), ShouldBeTrue)
+		So(IsArchive("thing.txt"), ShouldBeFalse)
+		joined := JoinArchivePath("a.zip", "/b/c.txt")
+		So(joined, ShouldEqual, "a.zip!/b/c.txt")
+		archive, member, ok := SplitArchivePath(joined)
+		So(ok, ShouldBeTrue)
+		So(archive, ShouldEqual, "a.zip")
+		So(member, ShouldEqual, "b/c.txt")
+		_, _, ok = SplitArchivePath("a.zip")
+		So(ok, ShouldBeFalse)
+		So(walkArchive(cOsWalker{}, "a.txt", 0, nil), ShouldEqual, ErrNotArchive)
+	})
+
+	Convey("FindAllMatcherWith archives", t, func() {
+		dir := tMakeArchives(t)
+		matcher := func(data []byte) (matched bool) {
+			matched = bytes.Contains(data, []byte("db.internal"))
+			return
+		}
+
+		Convey("archives as binary", func(c C) {
+			files, matches, err := FindAllMatcherWith([]string{dir}, FindOptions{Recurse: true}, func(file string, matched bool, err error) {
+				c.So(err, ShouldEqual, ErrBinaryFile)
+			}, matcher)
+			So(err, ShouldBeNil)
+			So(len(files), ShouldEqual, 4)
+			So(matches, ShouldBeEmpty)
+		})
+
+		Convey("descend into archives", func() {
+			errs := map[string]error{}
+			files, matches, err := FindAllMatcherWith([]string{dir}, FindOptions{Recurse: true, Archives: true}, func(file string, matched bool, err error) {
+				errs[file] = err
+			}, matcher)
+			So(err, ShouldBeNil)
+			So(len(files), ShouldEqual, 10)
+			So(matches, ShouldResemble, []string{
+				filepath.Join(dir, "hosts.txt.gz") + "!/hosts.txt",
+				filepath.Join(dir, "release.jar") + "!/docs/hosts.txt",
+				filepath.Join(dir, "release.tar") + "!/docs/hosts.txt",
+				filepath.Join(dir, "release.tar.gz") + "!/docs/hosts.txt",
+			})
+			So(errs[filepath.Join(dir, "release.tar")+"!/bin/tool"], ShouldEqual, ErrBinaryFile)
+		})
+
+		Convey("include, exclude and hidden members", func() {
+			include, _ := globs.Parse("*.txt", "*/.hidden")
+			exclude, _ := globs.Parse("*.gz")
+			files, matches, err := FindAllMatcherWith([]string{dir}, FindOptions{
+				Recurse:       true,
+				Archives:      true,
+				IncludeHidden: true,
+				Include:       include,
+				Exclude:       exclude,
+			}, nil, matcher)
+			So(err, ShouldBeNil)
+			So(len(files), ShouldEqual, 4)
+			So(len(matches), ShouldEqual, 4)
+		})
+
+		Convey("hidden member directories", func() {
+			target := filepath.Join(dir, "repo.tar")
+			So(os.WriteFile(target, tMakeTar([]tArchiveMember{
+				{".git/config", "url = db.internal\n"},
+				{"./.cache/hosts.txt", "db.internal\n"},
+				{"./docs/hosts.txt", "db.internal\n"},
+				{"../up.txt", "db.internal\n"},
+			}), 0644), ShouldBeNil)
+			files, matches, err := FindAllMatcherWith([]string{target}, FindOptions{Archives: true}, nil, matcher)
+			So(err, ShouldBeNil)
+			So(files, ShouldResemble, []string{
+				JoinArchivePath(target, "./docs/hosts.txt"),
+				JoinArchivePath(target, "../up.txt"),
+			})
+			So(matches, ShouldHaveLength, 2)
+			files, _, err = FindAllMatcherWith([]string{target}, FindOptions{Archives: true, IncludeHidden: true}, nil, matcher)
+			So(err, ShouldBeNil)
+			So(files, ShouldHaveLength, 4)
+		})
+
+		Convey("corrupt archive", func() {
+			broken := filepath.Join(dir, "broken.zip")
+			So(os.WriteFile(broken, []byte("not a zip"), 0644), ShouldBeNil)
+			var reported error
+			files, _, err := FindAllMatcherWith([]string{broken}, FindOptions{Archives: true}, func(file string, matched bool, err error) {
+				reported = err
+			}, matcher)
+			So(err, ShouldBeNil)
+			So(files, ShouldResemble, []string{broken})
+			So(reported, ShouldNotBeNil)
+		})
+
+		Convey("oversized members", func() {
+			origMaxFileSize := MaxFileSize
+			defer func() {
+				MaxFileSize = origMaxFileSize
+			}()
+			MaxFileSize = 1024
+			large := strings.Repeat("db.internal\n", 1024)
+			targets := []string{
+				filepath.Join(dir, "large.txt.gz"),
+				filepath.Join(dir, "large.zip"),
+			}
+			So(os.WriteFile(targets[0], tMakeGzip("", []byte(large)), 0644), ShouldBeNil)
+			So(os.WriteFile(targets[1], tMakeZip([]tArchiveMember{{"large.txt", large}}), 0644), ShouldBeNil)
+			errs := map[string]error{}
+			files, matches, err := FindAllMatcherWith(targets, FindOptions{Archives: true}, func(file string, matched bool, err error) {
+				errs[file] = err
+			}, matcher)
+			So(err, ShouldBeNil)
+			So(files, ShouldHaveLength, 2)
+			So(matches, ShouldBeEmpty)
+			So(errs[targets[0]+"!/large.txt"], ShouldEqual, ErrLargeFile)
+			So(errs[targets[1]+"!/large.txt"], ShouldEqual, ErrLargeFile)
+			_, matches, err = FindAllMatcherWith(targets, FindOptions{Archives: true, NoLimit: true}, nil, matcher)
+			So(err, ShouldBeNil)
+			So(matches, ShouldHaveLength, 2)
+		})
+	})
+
+	Convey("ProcessArchive", t, func() {
+		dir := tMakeArchives(t)
+		upper := func(original string) (modified string, count int) {
+			modified, count = String("db.internal", "db.public", original)
+			return
+		}
+
+		Convey("zip", func() {
+			var buffer bytes.Buffer
+			target := filepath.Join(dir, "release.jar")
+			deltas, count, err := ProcessArchive(target, &buffer, nil, nil, upper)
+			So(err, ShouldBeNil)
+			So(count, ShouldEqual, 2)
+			So(len(deltas), ShouldEqual, 2)
+			So(deltas[0].Len(), ShouldEqual, 2)
+
+			zo, err := zip.OpenReader(target)
+			So(err, ShouldBeNil)
+			defer zo.Close()
+			zm, err := zip.NewReader(bytes.NewReader(buffer.Bytes()), int64(buffer.Len()))
+			So(err, ShouldBeNil)
+			So(len(zm.File), ShouldEqual, len(zo.File))
+			for idx, zf := range zm.File {
+				So(zf.Name, ShouldEqual, tArchiveMembers[idx].name)
+				So(zf.Mode(), ShouldEqual, zo.File[idx].Mode())
+				So(zf.Modified.Equal(zo.File[idx].Modified), ShouldBeTrue)
+			}
+			// modified members keep their extra fields, without duplicates
+			for _, idx := range []int{0, 1} {
+				So(zm.File[idx].Extra, ShouldResemble, zo.File[idx].Extra)
+			}
+			// untouched members are copied byte-for-byte
+			for _, idx := range []int{2, 3} {
+				a, _ := zo.File[idx].OpenRaw()
+				b, _ := zm.File[idx].OpenRaw()
+				ad, _ := io.ReadAll(a)
+				bd, _ := io.ReadAll(b)
+				So(bd, ShouldResemble, ad)
+			}
+			rc, err := zm.File[0].Open()
+			So(err, ShouldBeNil)
+			data, _ := io.ReadAll(rc)
+			So(string(data), ShouldEqual, "db.public.example.com\n")
+		})
+
+		Convey("tar.gz", func() {
+			var buffer bytes.Buffer
+			include, _ := globs.Parse("*.txt")
+			target := filepath.Join(dir, "release.tar.gz")
+			deltas, count, err := ProcessArchive(target, &buffer, include, nil, upper)
+			So(err, ShouldBeNil)
+			So(count, ShouldEqual, 1)
+			So(len(deltas), ShouldEqual, 1)
+			unified, _ := deltas[0].Unified()
+			So(unified, ShouldContainSubstring, "release.tar.gz!/docs/hosts.txt")
+
+			gr, err := gzip.NewReader(&buffer)
+			So(err, ShouldBeNil)
+			tr := tar.NewReader(gr)
+			for idx := 0; ; idx++ {
+				hdr, err := tr.Next()
+				if err == io.EOF {
+					So(idx, ShouldEqual, len(tArchiveMembers))
+					break
+				}
+				So(err, ShouldBeNil)
+				So(hdr.Name, ShouldEqual, tArchiveMembers[idx].name)
+				So(hdr.Mode, ShouldEqual, 0640)
+				So(hdr.ModTime.Equal(tArchiveModTime), ShouldBeTrue)
+				data, _ := io.ReadAll(tr)
+				if idx == 0 {
+					So(string(data), ShouldEqual, "db.public.example.com\n")
+				} else {
+					So(string(data), ShouldEqual, tArchiveMembers[idx].body)
+				}
+			}
+		})
+
+		Convey("gz", func() {
+			var buffer bytes.Buffer
+			deltas, count, err := ProcessArchive(filepath.Join(dir, "hosts.txt.gz"), &buffer, nil, nil, upper)
+			So(err, ShouldBeNil)
+			So(count, ShouldEqual, 1)
+			So(len(deltas), ShouldEqual, 1)
+			gr, err := gzip.NewReader(&buffer)
+			So(err, ShouldBeNil)
+			data, _ := io.ReadAll(gr)
+			So(string(data), ShouldEqual, "db.public.example.com\n")
+		})
+
+		Convey("oversized gz", func() {
+			origMaxFileSize := MaxFileSize
+			defer func() {
+				MaxFileSize = origMaxFileSize
+			}()
+			MaxFileSize = 1024
+			large := strings.Repeat("db.internal\n", 1024)
+			target := filepath.Join(dir, "large.txt.gz")
+			So(os.WriteFile(target, tMakeGzip("", []byte(large)), 0644), ShouldBeNil)
+			var buffer bytes.Buffer
+			deltas, count, err := ProcessArchive(target, &buffer, nil, nil, upper)
+			So(err, ShouldBeNil)
+			So(count, ShouldEqual, 0)
+			So(deltas, ShouldBeEmpty)
+			gr, err := gzip.NewReader(&buffer)
+			So(err, ShouldBeNil)
+			data, _ := io.ReadAll(gr)
+			So(string(data), ShouldEqual, large)
+		})
+
+		Convey("errors", func() {
+			var buffer bytes.Buffer
+			_, _, err := ProcessArchive(filepath.Join(dir, "nope.txt"), &buffer, nil, nil, upper)
+			So(err, ShouldEqual, ErrNotArchive)
+			_, _, err = ProcessArchive(filepath.Join(dir, "nope.zip"), &buffer, nil, nil, upper)
+			So(err, ShouldNotBeNil)
+			_, _, err = ProcessArchive(filepath.Join(dir, "nope.tgz"), &buffer, nil, nil, upper)
+			So(err, ShouldNotBeNil)
+		})
+	})
+
+}
@@ -0,0 +1,114 @@
+// Copyright (c) 2024  The Go-Curses Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package replace
+
+import (
+	"bytes"
+	"errors"
+	"regexp"
+)
+
+var (
+	// ErrBinaryMatch is the error wrapped by each BinaryMatch
+	ErrBinaryMatch = errors.New("binary file matches")
+)
+
+// BinaryMode specifies how the finders handle binary files when the
+// BinAsText option is false
+type BinaryMode uint8
+
+const (
+	// BinarySkip reports binary files with ErrBinaryFile, without reading
+	// their content
+	BinarySkip BinaryMode = iota
+	// BinarySearch matches the raw bytes of binary files, reporting the
+	// matching ones with a *BinaryMatch error. Binary matches are never
+	// included in the `matches` lists, so that text replacements are not
+	// applied to them
+	BinarySearch
+)
+
+// FindAllLocatorFn is the function signature for locating the byte offsets of
+// each match within the raw `data` of a binary file
+type FindAllLocatorFn func(data []byte) (offsets []int)
+
+// BinaryMatch describes a binary file with content matching the search,
+// similar to grep's "Binary file matches" message
+type BinaryMatch struct {
+	// Path is the binary file or archive member
+	Path string
+	// Offsets are the byte offsets of the start of each match, nil when no
+	// FindOptions.Locator is set
+	Offsets []int
+}
+
+func (m *BinaryMatch) Error() (message string) {
+	message = m.Path + ": " + ErrBinaryMatch.Error()
+	return
+}
+
+func (m *BinaryMatch) Unwrap() (err error) {
+	err = ErrBinaryMatch
+	return
+}
+
+// LocateString returns a FindAllLocatorFn for the non-overlapping offsets of
+// the `search` bytes
+func LocateString(search string) (locator FindAllLocatorFn) {
+	needle := []byte(search)
+	locator = func(data []byte) (offsets []int) {
+		if len(needle) == 0 {
+			return
+		}
+		for start := 0; start < len(data); {
+			idx := bytes.Index(data[start:], needle)
+			if idx < 0 {
+				break
+			}
+			offsets = append(offsets, start+idx)
+			start += idx + len(needle)
+		}
+		return
+	}
+	return
+}
+
+// LocateRegexp returns a FindAllLocatorFn for the offsets of each match of the
+// `search` pattern
+func LocateRegexp(search *regexp.Regexp) (locator FindAllLocatorFn) {
+	locator = func(data []byte) (offsets []int) {
+		for _, loc := range search.FindAllIndex(data, -1) {
+			offsets = append(offsets, loc[0])
+		}
+		return
+	}
+	return
+}
+
+// binary matches the raw `data` of the binary `target`, returning a
+// *BinaryMatch when `matched`
+func (f *cFinder) binary(target string, data []byte) (matched bool, ee error) {
+	var offsets []int
+	if f.options.Locator != nil {
+		offsets = f.options.Locator(data)
+		matched = len(offsets) > 0
+	} else {
+		matched = f.matcher(data)
+	}
+	if matched {
+		ee = &BinaryMatch{Path: target, Offsets: offsets}
+	}
+	return
+}
@@ -0,0 +1,128 @@
+// Copyright (c) 2024  The Go-Curses Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package replace
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestBinary(t *testing.T) {
+	Convey("locators", t, func() {
+		So(LocateString("ab")([]byte("abcabab\x00ab")), ShouldResemble, []int{0, 3, 5, 8})
+		So(LocateString("aa")([]byte("aaaa")), ShouldResemble, []int{0, 2})
+		So(LocateString("")([]byte("abc")), ShouldBeNil)
+		So(LocateString("z")([]byte("abc")), ShouldBeNil)
+		So(LocateRegexp(regexp.MustCompile(`a+b`))([]byte("xab\x00aaab")), ShouldResemble, []int{1, 4})
+	})
+
+	Convey("BinarySearch", t, func() {
+		dir := t.TempDir()
+		_ = os.WriteFile(filepath.Join(dir, "app.bin"), []byte("\x7fELF\x00\x00needle\x00needle"), 0644)
+		_ = os.WriteFile(filepath.Join(dir, "other.bin"), []byte("\x7fELF\x00\x00nothing"), 0644)
+		_ = os.WriteFile(filepath.Join(dir, "notes.txt"), []byte("a needle in text\n"), 0644)
+		matcher := func(data []byte) (matched bool) {
+			return strings.Contains(string(data), "needle")
+		}
+		type tracked struct {
+			file    string
+			matched bool
+			err     error
+		}
+
+		Convey("skipped by default", func() {
+			var binaries int
+			files, matches, err := FindAllMatcherWith([]string{dir}, FindOptions{Recurse: true}, func(file string, matched bool, err error) {
+				if errors.Is(err, ErrBinaryFile) {
+					binaries += 1
+				}
+			}, matcher)
+			So(err, ShouldBeNil)
+			So(files, ShouldHaveLength, 3)
+			So(matches, ShouldResemble, []string{filepath.Join(dir, "notes.txt")})
+			So(binaries, ShouldEqual, 2)
+		})
+
+		Convey("reports offsets without matching for replacement", func() {
+			var results []tracked
+			files, matches, err := FindAllMatcherWith([]string{dir}, FindOptions{
+				Recurse: true,
+				Binary:  BinarySearch,
+				Locator: LocateString("needle"),
+			}, func(file string, matched bool, err error) {
+				results = append(results, tracked{filepath.Base(file), matched, err})
+			}, matcher)
+			So(err, ShouldBeNil)
+			So(files, ShouldHaveLength, 3)
+			So(matches, ShouldResemble, []string{filepath.Join(dir, "notes.txt")})
+			So(results, ShouldHaveLength, 3)
+			So(results[0].file, ShouldEqual, "app.bin")
+			So(results[0].matched, ShouldBeTrue)
+			So(errors.Is(results[0].err, ErrBinaryMatch), ShouldBeTrue)
+			var bm *BinaryMatch
+			So(errors.As(results[0].err, &bm), ShouldBeTrue)
+			So(bm.Path, ShouldEqual, filepath.Join(dir, "app.bin"))
+			So(bm.Offsets, ShouldResemble, []int{6, 13})
+			So(bm.Error(), ShouldEqual, filepath.Join(dir, "app.bin")+": binary file matches")
+			So(results[1], ShouldResemble, tracked{"notes.txt", true, nil})
+			So(results[2], ShouldResemble, tracked{"other.bin", false, nil})
+		})
+
+		Convey("uses the matcher without a locator", func() {
+			var found *BinaryMatch
+			err := FindAllStream([]string{filepath.Join(dir, "app.bin")}, FindOptions{Binary: BinarySearch}, matcher, func(result FindResult) (proceed bool) {
+				So(result.Matched, ShouldBeTrue)
+				So(errors.As(result.Err, &found), ShouldBeTrue)
+				return true
+			})
+			So(err, ShouldBeNil)
+			So(found, ShouldNotBeNil)
+			So(found.Offsets, ShouldBeNil)
+		})
+
+		Convey("BinAsText takes precedence", func() {
+			_, matches, err := FindAllMatcherWith([]string{dir}, FindOptions{Recurse: true, BinAsText: true, Binary: BinarySearch}, nil, matcher)
+			So(err, ShouldBeNil)
+			So(matches, ShouldHaveLength, 2)
+		})
+
+		Convey("archive members", func() {
+			archives := tMakeArchives(t)
+			var offsets []int
+			_, matches, err := FindAllMatcherWith([]string{filepath.Join(archives, "release.tar")}, FindOptions{
+				Archives: true,
+				Binary:   BinarySearch,
+				Locator:  LocateString("db.internal"),
+			}, func(file string, matched bool, err error) {
+				var bm *BinaryMatch
+				if errors.As(err, &bm) {
+					So(bm.Path, ShouldEqual, JoinArchivePath(filepath.Join(archives, "release.tar"), "bin/tool"))
+					offsets = bm.Offsets
+				}
+			}, func(data []byte) (matched bool) {
+				return strings.Contains(string(data), "db.internal")
+			})
+			So(err, ShouldBeNil)
+			So(matches, ShouldHaveLength, 1)
+			So(offsets, ShouldResemble, []int{7})
+		})
+	})
+}
@@ -0,0 +1,180 @@
+// Copyright (c) 2024  The Go-Curses Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package replace
+
+import (
+	"bytes"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/gabriel-vasile/mimetype"
+)
+
+// DefaultBinaryDetector is the BinaryDetector used when none is given, it
+// sniffs the first 8KB for NUL bytes and more than 30% invalid UTF-8, which
+// leaves room for short legacy Latin-1 text
+var DefaultBinaryDetector = SniffDetector(8192, 0.3)
+
+// BinaryDetector decides whether files are binary, based on their name and
+// a sample of their leading bytes
+type BinaryDetector interface {
+	// SampleSize is the number of leading bytes needed by IsBinary, zero if
+	// the content is not needed at all
+	SampleSize() (size int)
+	// IsBinary returns true if the file `name`, starting with the `sample`
+	// bytes given, is binary. The `sample` is shorter than the SampleSize
+	// when the file is smaller
+	IsBinary(name string, sample []byte) (binary bool)
+}
+
+// SniffDetector returns a BinaryDetector which reads up to `sampleSize` bytes,
+// detecting binary files as those with any NUL bytes or with a ratio of
+// invalid UTF-8 bytes greater than `maxInvalid`. UTF-16 text, which is full
+// of NUL bytes, is always detected as text
+func SniffDetector(sampleSize int, maxInvalid float64) (detector BinaryDetector) {
+	detector = cSniffDetector{size: sampleSize, ratio: maxInvalid}
+	return
+}
+
+type cSniffDetector struct {
+	size  int
+	ratio float64
+}
+
+func (d cSniffDetector) SampleSize() (size int) {
+	size = d.size
+	return
+}
+
+func (d cSniffDetector) IsBinary(_ string, sample []byte) (binary bool) {
+	sample = sample[:min(len(sample), d.size)]
+	switch DetectEncoding(sample[:len(sample)-len(sample)%2]).Charset {
+	case CharsetUTF16LE, CharsetUTF16BE:
+		return
+	}
+	if binary = bytes.IndexByte(sample, 0) >= 0; binary {
+		return
+	}
+	var invalid int
+	for idx := 0; idx < len(sample); {
+		r, width := utf8.DecodeRune(sample[idx:])
+		if r == utf8.RuneError && width == 1 {
+			if !utf8.FullRune(sample[idx:]) {
+				// truncated by the end of the sample
+				break
+			}
+			invalid += 1
+		}
+		idx += width
+	}
+	binary = float64(invalid) > d.ratio*float64(len(sample))
+	return
+}
+
+// MimeDetector returns a BinaryDetector which detects the MIME type of the
+// content, with anything other than the `textTypes` given (or their
+// sub-types) being binary. The `textTypes` default to "text/*", see
+// FileMimeType for the matching rules
+func MimeDetector(textTypes ...string) (detector BinaryDetector) {
+	if len(textTypes) == 0 {
+		textTypes = []string{"text/*"}
+	}
+	detector = cMimeDetector{types: textTypes}
+	return
+}
+
+type cMimeDetector struct {
+	types []string
+}
+
+func (d cMimeDetector) SampleSize() (size int) {
+	// the default mimetype read limit
+	size = 3072
+	return
+}
+
+func (d cMimeDetector) IsBinary(_ string, sample []byte) (binary bool) {
+	binary = !isMimeType(mimetype.Detect(sample), d.types)
+	return
+}
+
+// ExtensionDetector returns a BinaryDetector which detects binary files by
+// their name alone, ending with any of the `extensions` given in a
+// case-insensitive way. Extensions can have multiple parts, such as ".tar.gz",
+// and the leading period is optional
+func ExtensionDetector(extensions ...string) (detector BinaryDetector) {
+	d := cExtensionDetector{}
+	for _, ext := range extensions {
+		if ext = strings.ToLower(ext); !strings.HasPrefix(ext, ".") {
+			ext = "." + ext
+		}
+		d.extensions = append(d.extensions, ext)
+	}
+	detector = d
+	return
+}
+
+type cExtensionDetector struct {
+	extensions []string
+}
+
+func (d cExtensionDetector) SampleSize() (size int) {
+	return
+}
+
+func (d cExtensionDetector) IsBinary(name string, _ []byte) (binary bool) {
+	name = strings.ToLower(name)
+	for _, ext := range d.extensions {
+		if binary = strings.HasSuffix(name, ext); binary {
+			return
+		}
+	}
+	return
+}
+
+// AnyDetector returns a BinaryDetector which detects binary files when any of
+// the `detectors` given do, checking them in order
+func AnyDetector(detectors ...BinaryDetector) (detector BinaryDetector) {
+	detector = cDetectors{detectors: detectors}
+	return
+}
+
+// AllDetector returns a BinaryDetector which detects binary files only when
+// all of the `detectors` given do, checking them in order
+func AllDetector(detectors ...BinaryDetector) (detector BinaryDetector) {
+	detector = cDetectors{detectors: detectors, all: true}
+	return
+}
+
+type cDetectors struct {
+	detectors []BinaryDetector
+	all       bool
+}
+
+func (d cDetectors) SampleSize() (size int) {
+	for _, detector := range d.detectors {
+		size = max(size, detector.SampleSize())
+	}
+	return
+}
+
+func (d cDetectors) IsBinary(name string, sample []byte) (binary bool) {
+	for _, detector := range d.detectors {
+		if binary = detector.IsBinary(name, sample[:min(len(sample), detector.SampleSize())]); binary != d.all {
+			return
+		}
+	}
+	return
+}
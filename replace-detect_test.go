@@ -0,0 +1,108 @@
+// Copyright (c) 2024  The Go-Curses Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package replace
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestBinaryDetectors(t *testing.T) {
+	png := []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")
+	minified := []byte(strings.Repeat(`var a=function(b){return b+"é"};`, 500))
+
+	Convey("SniffDetector", t, func() {
+		d := SniffDetector(16, 0.25)
+		So(d.SampleSize(), ShouldEqual, 16)
+		So(d.IsBinary("", nil), ShouldBeFalse)
+		So(d.IsBinary("", []byte("plain text")), ShouldBeFalse)
+		So(d.IsBinary("", minified), ShouldBeFalse)
+		So(d.IsBinary("", png), ShouldBeTrue)
+		So(d.IsBinary("", []byte{'h', 0, 'i', 0, '!', 0, '\n', 0}), ShouldBeFalse)
+		// one invalid byte in five is allowed, two in six are not
+		So(d.IsBinary("", []byte("caf\xe9 ")), ShouldBeFalse)
+		So(d.IsBinary("", []byte("c\xffaf\xe9 ")), ShouldBeTrue)
+		// a rune cut short by the sample size is not invalid
+		So(d.IsBinary("", []byte("0123456789abcd\xc3\xa9")), ShouldBeFalse)
+		// NUL bytes past the sample size are not seen
+		So(d.IsBinary("", []byte("0123456789abcdef\x00")), ShouldBeFalse)
+	})
+
+	Convey("MimeDetector", t, func() {
+		d := MimeDetector()
+		So(d.SampleSize(), ShouldEqual, 3072)
+		So(d.IsBinary("", png), ShouldBeTrue)
+		So(d.IsBinary("", minified), ShouldBeFalse)
+		So(d.IsBinary("", []byte(`{"json": true}`)), ShouldBeFalse)
+		d = MimeDetector("image/png")
+		So(d.IsBinary("", png), ShouldBeFalse)
+		So(d.IsBinary("", []byte("plain text")), ShouldBeTrue)
+	})
+
+	Convey("ExtensionDetector", t, func() {
+		d := ExtensionDetector("png", ".TAR.GZ")
+		So(d.SampleSize(), ShouldEqual, 0)
+		So(d.IsBinary("image.PNG", nil), ShouldBeTrue)
+		So(d.IsBinary("dir/release.tar.gz", nil), ShouldBeTrue)
+		So(d.IsBinary("notes.gz", nil), ShouldBeFalse)
+		So(d.IsBinary("png", nil), ShouldBeFalse)
+	})
+
+	Convey("AnyDetector and AllDetector", t, func() {
+		ext := ExtensionDetector(".js")
+		sniff := SniffDetector(8, 0)
+		So(AnyDetector(ext, sniff).SampleSize(), ShouldEqual, 8)
+		So(AnyDetector(ext, sniff).IsBinary("app.js", []byte("text")), ShouldBeTrue)
+		So(AnyDetector(ext, sniff).IsBinary("app.txt", png), ShouldBeTrue)
+		So(AnyDetector(ext, sniff).IsBinary("app.txt", []byte("text")), ShouldBeFalse)
+		So(AllDetector(ext, sniff).IsBinary("app.js", []byte("text")), ShouldBeFalse)
+		So(AllDetector(ext, sniff).IsBinary("app.js", png), ShouldBeTrue)
+		So(AnyDetector().IsBinary("app.js", png), ShouldBeFalse)
+	})
+
+	Convey("FindOptions.Detector", t, func() {
+		dir := tMakeTree(t, "app.js", "notes.txt")
+		var binaries []string
+		_, matches, err := FindAllMatcherWith([]string{dir}, FindOptions{
+			Recurse:  true,
+			Detector: ExtensionDetector(".js"),
+		}, func(file string, matched bool, err error) {
+			if errors.Is(err, ErrBinaryFile) {
+				binaries = append(binaries, file)
+			}
+		}, func(data []byte) (matched bool) {
+			return true
+		})
+		So(err, ShouldBeNil)
+		So(matches, ShouldResemble, []string{filepath.Join(dir, "notes.txt")})
+		So(binaries, ShouldResemble, []string{filepath.Join(dir, "app.js")})
+
+		fsys := fstest.MapFS{
+			"image.dat":   {Data: png},
+			"bundle.js":   {Data: minified},
+			"utf16le.txt": {Data: []byte{'h', 0, 'i', 0, '\n', 0}},
+		}
+		_, matches, err = FindAllMatcherWith([]string{"."}, FindOptions{Recurse: true, FS: fsys}, nil, func(data []byte) (matched bool) {
+			return true
+		})
+		So(err, ShouldBeNil)
+		So(matches, ShouldResemble, []string{"bundle.js", "utf16le.txt"})
+	})
+}
@@ -0,0 +1,298 @@
+// Copyright (c) 2024  The Go-Curses Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package replace
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+var (
+	ErrInvalidEncoding = errors.New("invalid encoding")
+	ErrUnencodable     = errors.New("unencodable character")
+)
+
+var (
+	bomUTF8    = []byte{0xef, 0xbb, 0xbf}
+	bomUTF16LE = []byte{0xff, 0xfe}
+	bomUTF16BE = []byte{0xfe, 0xff}
+)
+
+// Charset is a simple type for indicating a detected text character set
+type Charset uint8
+
+const (
+	CharsetUTF8 Charset = iota
+	CharsetUTF16LE
+	CharsetUTF16BE
+	CharsetLatin1
+	CharsetWindows1252
+)
+
+// String returns the common name of the character set
+func (c Charset) String() (name string) {
+	switch c {
+	case CharsetUTF8:
+		name = "UTF-8"
+	case CharsetUTF16LE:
+		name = "UTF-16LE"
+	case CharsetUTF16BE:
+		name = "UTF-16BE"
+	case CharsetLatin1:
+		name = "ISO-8859-1"
+	case CharsetWindows1252:
+		name = "Windows-1252"
+	}
+	return
+}
+
+// Encoding describes how text is stored on disk, the Charset used and
+// whether the text was prefixed with a byte order mark
+type Encoding struct {
+	Charset Charset
+	BOM     bool
+}
+
+// String returns the Charset name, suffixed with "+BOM" when a byte order
+// mark is present
+func (e Encoding) String() (name string) {
+	if name = e.Charset.String(); e.BOM {
+		name += "+BOM"
+	}
+	return
+}
+
+// IsUTF8 returns true if the Encoding is UTF-8 without a byte order mark,
+// which is the only Encoding that needs no transcoding at all
+func (e Encoding) IsUTF8() (plain bool) {
+	plain = e.Charset == CharsetUTF8 && !e.BOM
+	return
+}
+
+// DetectEncoding sniffs the given `data` for a byte order mark and when none
+// is present, uses the following heuristics to pick an Encoding:
+//
+//   - an even number of bytes with most of the odd (or even) bytes being NUL
+//     is UTF-16LE (or UTF-16BE)
+//   - valid UTF-8 is UTF-8, as is mostly valid UTF-8 with fewer stray bytes
+//     than multibyte characters
+//   - invalid UTF-8 using any of the Windows-1252 printable characters in the
+//     0x80-0x9f range is Windows-1252
+//   - all other invalid UTF-8 is ISO-8859-1
+func DetectEncoding(data []byte) (enc Encoding) {
+	switch {
+	case bytes.HasPrefix(data, bomUTF8):
+		enc = Encoding{Charset: CharsetUTF8, BOM: true}
+	case bytes.HasPrefix(data, bomUTF16LE):
+		enc = Encoding{Charset: CharsetUTF16LE, BOM: true}
+	case bytes.HasPrefix(data, bomUTF16BE):
+		enc = Encoding{Charset: CharsetUTF16BE, BOM: true}
+	default:
+		enc.Charset = detectCharset(data)
+	}
+	return
+}
+
+func detectCharset(data []byte) (charset Charset) {
+	if size := len(data); size >= 2 && size%2 == 0 {
+		var even, odd int
+		for idx, b := range data {
+			if b == 0 {
+				if idx%2 == 0 {
+					even += 1
+				} else {
+					odd += 1
+				}
+			}
+		}
+		// at least a third of one side is NUL and the other side nearly none
+		pairs := size / 2
+		if odd*3 >= pairs && even*10 <= pairs {
+			charset = CharsetUTF16LE
+			return
+		} else if even*3 >= pairs && odd*10 <= pairs {
+			charset = CharsetUTF16BE
+			return
+		}
+	}
+	if utf8.Valid(data) || isMostlyUTF8(data) {
+		charset = CharsetUTF8
+		return
+	}
+	charset = CharsetLatin1
+	for _, b := range data {
+		if b >= 0x80 && b <= 0x9f && gWindows1252[b-0x80] != rune(b) {
+			charset = CharsetWindows1252
+			return
+		}
+	}
+	return
+}
+
+// isMostlyUTF8 returns true if the invalid UTF-8 `data` has more multibyte
+// characters than stray bytes, such as UTF-8 text with a corrupt byte, which
+// would be mangled if decoded as ISO-8859-1
+func isMostlyUTF8(data []byte) (mostly bool) {
+	var multibyte, invalid int
+	for idx := 0; idx < len(data); {
+		r, width := utf8.DecodeRune(data[idx:])
+		if r == utf8.RuneError && width == 1 {
+			invalid += 1
+		} else if width > 1 {
+			multibyte += 1
+		}
+		idx += width
+	}
+	mostly = invalid < multibyte
+	return
+}
+
+// Decode transcodes the given `data` from this Encoding into a UTF-8 string,
+// removing the byte order mark if present
+func (e Encoding) Decode(data []byte) (text string, err error) {
+	switch e.Charset {
+
+	case CharsetUTF8:
+		if e.BOM {
+			data = bytes.TrimPrefix(data, bomUTF8)
+		}
+		text = string(data)
+
+	case CharsetUTF16LE, CharsetUTF16BE:
+		if e.BOM {
+			data = data[min(len(data), 2):]
+		}
+		if len(data)%2 != 0 {
+			err = fmt.Errorf("%w: odd number of %s bytes", ErrInvalidEncoding, e.Charset)
+			return
+		}
+		units := make([]uint16, len(data)/2)
+		for idx := range units {
+			lo, hi := data[idx*2], data[idx*2+1]
+			if e.Charset == CharsetUTF16BE {
+				lo, hi = hi, lo
+			}
+			units[idx] = uint16(lo) | uint16(hi)<<8
+		}
+		text = string(utf16.Decode(units))
+
+	case CharsetLatin1, CharsetWindows1252:
+		var buffer strings.Builder
+		buffer.Grow(len(data))
+		for _, b := range data {
+			if e.Charset == CharsetWindows1252 && b >= 0x80 && b <= 0x9f {
+				buffer.WriteRune(gWindows1252[b-0x80])
+			} else {
+				buffer.WriteRune(rune(b))
+			}
+		}
+		text = buffer.String()
+
+	default:
+		err = fmt.Errorf("%w: unknown charset %d", ErrInvalidEncoding, e.Charset)
+	}
+	return
+}
+
+// Encode transcodes the given UTF-8 `text` into this Encoding, prefixing the
+// byte order mark if the Encoding has one. Encode returns ErrUnencodable if
+// `text` has characters not supported by the Encoding
+func (e Encoding) Encode(text string) (data []byte, err error) {
+	switch e.Charset {
+
+	case CharsetUTF8:
+		if e.BOM {
+			data = append(data, bomUTF8...)
+		}
+		data = append(data, text...)
+
+	case CharsetUTF16LE, CharsetUTF16BE:
+		units := utf16.Encode([]rune(text))
+		data = make([]byte, 0, len(units)*2+2)
+		if e.BOM {
+			if e.Charset == CharsetUTF16LE {
+				data = append(data, bomUTF16LE...)
+			} else {
+				data = append(data, bomUTF16BE...)
+			}
+		}
+		for _, unit := range units {
+			if e.Charset == CharsetUTF16LE {
+				data = append(data, byte(unit), byte(unit>>8))
+			} else {
+				data = append(data, byte(unit>>8), byte(unit))
+			}
+		}
+
+	case CharsetLatin1, CharsetWindows1252:
+		data = make([]byte, 0, len(text))
+		for idx, r := range text {
+			b, ok := e.encodeByte(r)
+			if !ok {
+				err = fmt.Errorf("%w: %q at offset %d is not %s", ErrUnencodable, r, idx, e.Charset)
+				return
+			}
+			data = append(data, b)
+		}
+
+	default:
+		err = fmt.Errorf("%w: unknown charset %d", ErrInvalidEncoding, e.Charset)
+	}
+	return
+}
+
+func (e Encoding) encodeByte(r rune) (b byte, ok bool) {
+	if e.Charset == CharsetWindows1252 {
+		if r >= 0x80 && r <= 0x9f {
+			// only the undefined positions round-trip as C1 controls
+			if ok = gWindows1252[r-0x80] == r; ok {
+				b = byte(r)
+			}
+			return
+		}
+		for idx, c := range gWindows1252 {
+			if c == r {
+				b, ok = byte(0x80+idx), true
+				return
+			}
+		}
+	}
+	if ok = r >= 0 && r <= 0xff; ok {
+		b = byte(r)
+	}
+	return
+}
+
+// DecodeText is a convenience function which uses DetectEncoding to Decode
+// the given `data`, returning the Encoding detected so that the text can be
+// Encoded again later
+func DecodeText(data []byte) (text string, enc Encoding, err error) {
+	enc = DetectEncoding(data)
+	text, err = enc.Decode(data)
+	return
+}
+
+// gWindows1252 maps the 0x80-0x9f byte range to runes, the five undefined
+// positions are mapped to their C1 control codes so that they round-trip
+var gWindows1252 = [32]rune{
+	0x20ac, 0x0081, 0x201a, 0x0192, 0x201e, 0x2026, 0x2020, 0x2021,
+	0x02c6, 0x2030, 0x0160, 0x2039, 0x0152, 0x008d, 0x017d, 0x008f,
+	0x0090, 0x2018, 0x2019, 0x201c, 0x201d, 0x2022, 0x2013, 0x2014,
+	0x02dc, 0x2122, 0x0161, 0x203a, 0x0153, 0x009d, 0x017e, 0x0178,
+}
@@ -0,0 +1,84 @@
+// Copyright (c) 2024  The Go-Curses Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package replace
+
+import (
+	"errors"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+var (
+	tEncodingTestData = map[string]struct {
+		data    []byte
+		encoded Encoding
+		text    string
+	}{
+		"utf-8":         {[]byte("café"), Encoding{Charset: CharsetUTF8}, "café"},
+		"utf-8 bom":     {[]byte("\xef\xbb\xbfcafé"), Encoding{Charset: CharsetUTF8, BOM: true}, "café"},
+		"utf-16le bom":  {[]byte{0xff, 0xfe, 'h', 0, 'i', 0}, Encoding{Charset: CharsetUTF16LE, BOM: true}, "hi"},
+		"utf-16be bom":  {[]byte{0xfe, 0xff, 0, 'h', 0, 'i'}, Encoding{Charset: CharsetUTF16BE, BOM: true}, "hi"},
+		"utf-16le":      {[]byte{'h', 0, 'e', 0, 'l', 0, 'l', 0, 'o', 0, ' ', 0, 'h', 0, 'i', 0, ' ', 0, 0x3d, 0xd8, 0x00, 0xde}, Encoding{Charset: CharsetUTF16LE}, "hello hi \U0001f600"},
+		"utf-16be":      {[]byte{0, 'h', 0, 'i', 0, '!', 0, '\n'}, Encoding{Charset: CharsetUTF16BE}, "hi!\n"},
+		"utf-8 stray":   {[]byte("naïve café code\nstray byte: \xff\n"), Encoding{Charset: CharsetUTF8}, "naïve café code\nstray byte: \xff\n"},
+		"iso-8859-1":    {[]byte("caf\xe9 na\xefve"), Encoding{Charset: CharsetLatin1}, "café naïve"},
+		"windows-1252":  {[]byte("\x93caf\xe9\x94 \x80"), Encoding{Charset: CharsetWindows1252}, "“café” €"},
+		"windows-1252u": {[]byte("\x81\x93"), Encoding{Charset: CharsetWindows1252}, "\u0081“"},
+	}
+)
+
+func TestEncoding(t *testing.T) {
+
+	Convey("DetectEncoding", t, func() {
+		for _, test := range tEncodingTestData {
+			So(DetectEncoding(test.data), ShouldResemble, test.encoded)
+		}
+		So(DetectEncoding(nil), ShouldResemble, Encoding{Charset: CharsetUTF8})
+	})
+
+	Convey("Decode and Encode round-trip", t, func() {
+		for name, test := range tEncodingTestData {
+			Convey(name, func() {
+				text, enc, err := DecodeText(test.data)
+				So(err, ShouldBeNil)
+				So(enc, ShouldResemble, test.encoded)
+				So(text, ShouldEqual, test.text)
+				data, err := enc.Encode(text)
+				So(err, ShouldBeNil)
+				So(data, ShouldResemble, test.data)
+			})
+		}
+	})
+
+	Convey("Errors", t, func() {
+		_, err := Encoding{Charset: CharsetUTF16LE}.Decode([]byte{'h', 0, 'i'})
+		So(errors.Is(err, ErrInvalidEncoding), ShouldBeTrue)
+		_, err = Encoding{Charset: CharsetLatin1}.Encode("€")
+		So(errors.Is(err, ErrUnencodable), ShouldBeTrue)
+		_, err = Encoding{Charset: CharsetWindows1252}.Encode("\u0080")
+		So(errors.Is(err, ErrUnencodable), ShouldBeTrue)
+		_, err = Encoding{Charset: Charset(99)}.Encode("text")
+		So(errors.Is(err, ErrInvalidEncoding), ShouldBeTrue)
+	})
+
+	Convey("String", t, func() {
+		So(Encoding{Charset: CharsetUTF16LE, BOM: true}.String(), ShouldEqual, "UTF-16LE+BOM")
+		So(Encoding{Charset: CharsetWindows1252}.String(), ShouldEqual, "Windows-1252")
+		So(Encoding{}.IsUTF8(), ShouldBeTrue)
+		So(Encoding{BOM: true}.IsUTF8(), ShouldBeFalse)
+	})
+
+}
@@ -46,3 +46,33 @@ func RegexPreserveFile(search *regexp.Regexp, replace, target string) (original,
 	})
 	return
 }
+
+// RegexEncodedFile uses Regex to ProcessEncodedFile, use WriteEncodedFile
+// with the `enc` returned to save the `modified` text
+func RegexEncodedFile(search *regexp.Regexp, replace, target string) (original, modified string, count int, delta *diff.Diff, enc Encoding, err error) {
+	original, modified, count, delta, enc, err = ProcessEncodedFile(target, func(original string) (modified string, count int) {
+		modified, count = Regex(search, replace, original)
+		return
+	})
+	return
+}
+
+// RegexLinesEncodedFile uses RegexLines to ProcessEncodedFile, use WriteEncodedFile
+// with the `enc` returned to save the `modified` text
+func RegexLinesEncodedFile(search *regexp.Regexp, replace, target string) (original, modified string, count int, delta *diff.Diff, enc Encoding, err error) {
+	original, modified, count, delta, enc, err = ProcessEncodedFile(target, func(original string) (modified string, count int) {
+		modified, count = RegexLines(search, replace, original)
+		return
+	})
+	return
+}
+
+// RegexPreserveEncodedFile uses RegexPreserve to ProcessEncodedFile, use WriteEncodedFile
+// with the `enc` returned to save the `modified` text
+func RegexPreserveEncodedFile(search *regexp.Regexp, replace, target string) (original, modified string, count int, delta *diff.Diff, enc Encoding, err error) {
+	original, modified, count, delta, enc, err = ProcessEncodedFile(target, func(original string) (modified string, count int) {
+		modified, count = RegexPreserve(search, replace, original)
+		return
+	})
+	return
+}
@@ -45,4 +45,23 @@ func TestRegexFile(t *testing.T) {
 		So(count, ShouldEqual, 4)
 		So(diff.Len(), ShouldEqual, 6)
 	})
+
+	Convey("encoded files", t, func() {
+		target := tMakeUTF16File(t, "the café\nand The bistro\n")
+		_, modified, count, _, enc, err := RegexEncodedFile(regexp.MustCompile(`caf.`), "bar", target)
+		So(err, ShouldBeNil)
+		So(count, ShouldEqual, 1)
+		So(modified, ShouldEqual, "the bar\nand The bistro\n")
+		So(enc.Charset, ShouldEqual, CharsetUTF16LE)
+
+		_, modified, count, _, _, err = RegexLinesEncodedFile(regexp.MustCompile(`^(?i)the`), "a", target)
+		So(err, ShouldBeNil)
+		So(count, ShouldEqual, 1)
+		So(modified, ShouldEqual, "a café\nand The bistro\n")
+
+		_, modified, count, _, _, err = RegexPreserveEncodedFile(regexp.MustCompile(`(?i)the`), "this", target)
+		So(err, ShouldBeNil)
+		So(count, ShouldEqual, 2)
+		So(modified, ShouldEqual, "this café\nand This bistro\n")
+	})
 }
@@ -44,3 +44,33 @@ func StringPreserveFile(search, replace, target string) (original, modified stri
 	})
 	return
 }
+
+// StringEncodedFile uses String to ProcessEncodedFile, use WriteEncodedFile
+// with the `enc` returned to save the `modified` text
+func StringEncodedFile(search, replace, target string) (original, modified string, count int, delta *diff.Diff, enc Encoding, err error) {
+	original, modified, count, delta, enc, err = ProcessEncodedFile(target, func(original string) (modified string, count int) {
+		modified, count = String(search, replace, original)
+		return
+	})
+	return
+}
+
+// StringInsensitiveEncodedFile uses StringInsensitive to ProcessEncodedFile, use WriteEncodedFile
+// with the `enc` returned to save the `modified` text
+func StringInsensitiveEncodedFile(search, replace, target string) (original, modified string, count int, delta *diff.Diff, enc Encoding, err error) {
+	original, modified, count, delta, enc, err = ProcessEncodedFile(target, func(original string) (modified string, count int) {
+		modified, count = StringInsensitive(search, replace, original)
+		return
+	})
+	return
+}
+
+// StringPreserveEncodedFile uses StringPreserve to ProcessEncodedFile, use WriteEncodedFile
+// with the `enc` returned to save the `modified` text
+func StringPreserveEncodedFile(search, replace, target string) (original, modified string, count int, delta *diff.Diff, enc Encoding, err error) {
+	original, modified, count, delta, enc, err = ProcessEncodedFile(target, func(original string) (modified string, count int) {
+		modified, count = StringPreserve(search, replace, original)
+		return
+	})
+	return
+}
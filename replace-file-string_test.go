@@ -15,11 +15,20 @@
 package replace
 
 import (
+	"os"
+	"path/filepath"
 	"testing"
 
 	. "github.com/smartystreets/goconvey/convey"
 )
 
+func tMakeUTF16File(t *testing.T, text string) (target string) {
+	target = filepath.Join(t.TempDir(), "utf16.txt")
+	data, _ := Encoding{Charset: CharsetUTF16LE, BOM: true}.Encode(text)
+	_ = os.WriteFile(target, data, 0644)
+	return
+}
+
 func TestStringFile(t *testing.T) {
 
 	Convey("StringFile", t, func() {
@@ -46,4 +55,35 @@ func TestStringFile(t *testing.T) {
 		So(diff.Len(), ShouldEqual, 6)
 	})
 
+	Convey("encoded files", t, func() {
+		target := tMakeUTF16File(t, "the café and The bistro\n")
+		_, _, count, _, err := StringFile("café", "bar", target)
+		So(err, ShouldBeNil)
+		So(count, ShouldEqual, 0)
+
+		_, modified, count, delta, enc, err := StringEncodedFile("café", "bar", target)
+		So(err, ShouldBeNil)
+		So(count, ShouldEqual, 1)
+		So(modified, ShouldEqual, "the bar and The bistro\n")
+		So(delta, ShouldNotBeNil)
+		So(enc, ShouldResemble, Encoding{Charset: CharsetUTF16LE, BOM: true})
+		So(WriteEncodedFile(target, modified, enc), ShouldBeNil)
+		original, _, _, _, enc, err := ProcessEncodedFile(target, func(original string) (modified string, count int) {
+			return original, 0
+		})
+		So(err, ShouldBeNil)
+		So(original, ShouldEqual, "the bar and The bistro\n")
+		So(enc.Charset, ShouldEqual, CharsetUTF16LE)
+
+		_, modified, count, _, _, err = StringInsensitiveEncodedFile("THE", "a", target)
+		So(err, ShouldBeNil)
+		So(count, ShouldEqual, 2)
+		So(modified, ShouldEqual, "a bar and a bistro\n")
+
+		_, modified, count, _, _, err = StringPreserveEncodedFile("the", "this", target)
+		So(err, ShouldBeNil)
+		So(count, ShouldEqual, 2)
+		So(modified, ShouldEqual, "this bar and This bistro\n")
+	})
+
 }
@@ -32,3 +32,32 @@ func ProcessFile(target string, fn func(original string) (modified string, count
 	}
 	return
 }
+
+// ProcessEncodedFile is like ProcessFile except that the file contents are
+// decoded with DecodeText before running the given `fn` and the Encoding
+// detected is returned so that the `modified` text can be written back in
+// the original encoding with WriteEncodedFile
+func ProcessEncodedFile(target string, fn func(original string) (modified string, count int)) (original, modified string, count int, delta *diff.Diff, enc Encoding, err error) {
+	var data []byte
+	if data, err = os.ReadFile(target); err == nil {
+		if original, enc, err = DecodeText(data); err == nil {
+			modified, count = fn(original)
+			delta = diff.New(target, original, modified)
+		}
+	}
+	return
+}
+
+// WriteEncodedFile uses Encoding.Encode to transcode the `content` given and
+// overwrites the target file, preserving the existing file permissions
+func WriteEncodedFile(target, content string, enc Encoding) (err error) {
+	var data []byte
+	var info os.FileInfo
+	if info, err = os.Stat(target); err != nil {
+		return
+	} else if data, err = enc.Encode(content); err != nil {
+		return
+	}
+	err = os.WriteFile(target, data, info.Mode().Perm())
+	return
+}
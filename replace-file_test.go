@@ -15,6 +15,8 @@
 package replace
 
 import (
+	"os"
+	"path/filepath"
 	"testing"
 
 	. "github.com/smartystreets/goconvey/convey"
@@ -44,4 +46,30 @@ func TestReplaceFile(t *testing.T) {
 		So(err, ShouldNotEqual, nil)
 	})
 
+	Convey("ProcessEncodedFile", t, func() {
+		target := filepath.Join(t.TempDir(), "resource.rc")
+		So(os.WriteFile(target, []byte{0xff, 0xfe, 'o', 0, 'n', 0, 'e', 0}, 0640), ShouldBeNil)
+		original, modified, count, delta, enc, err := ProcessEncodedFile(target, func(original string) (modified string, count int) {
+			modified, count = String("one", "two", original)
+			return
+		})
+		So(err, ShouldBeNil)
+		So(original, ShouldEqual, "one")
+		So(modified, ShouldEqual, "two")
+		So(count, ShouldEqual, 1)
+		So(delta.Len(), ShouldEqual, 2)
+		So(enc, ShouldResemble, Encoding{Charset: CharsetUTF16LE, BOM: true})
+		So(WriteEncodedFile(target, modified, enc), ShouldBeNil)
+		data, err := os.ReadFile(target)
+		So(err, ShouldBeNil)
+		So(data, ShouldResemble, []byte{0xff, 0xfe, 't', 0, 'w', 0, 'o', 0})
+		info, err := os.Stat(target)
+		So(err, ShouldBeNil)
+		So(info.Mode().Perm(), ShouldEqual, os.FileMode(0640))
+		So(WriteEncodedFile(target, "€", Encoding{Charset: CharsetLatin1}), ShouldNotBeNil)
+		So(WriteEncodedFile(target+".nope", "", enc), ShouldNotBeNil)
+		_, _, _, _, _, err = ProcessEncodedFile(target+".nope", nil)
+		So(err, ShouldNotBeNil)
+	})
+
 }
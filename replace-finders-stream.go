@@ -0,0 +1,97 @@
+// Copyright (c) 2024  The Go-Curses Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package replace
+
+import (
+	"context"
+	"errors"
+)
+
+// errStreamStopped is the internal signal for a stream consumer stopping early
+var errStreamStopped = errors.New("stream stopped")
+
+// FindResult is a single file reported by the streaming finders
+type FindResult struct {
+	// File is the path of the file, archive member or walk error
+	File string
+	// Matched is true if the matcher returned true for the File content
+	Matched bool
+	// Err is a per-file problem, such as ErrLargeFile, ErrBinaryFile, a
+	// *BinaryMatch or a *WalkError for paths which could not be walked
+	Err error
+}
+
+// FindResultFn is the function signature for consuming streamed FindResults,
+// returning false stops the stream
+type FindResultFn func(result FindResult) (proceed bool)
+
+// FindAllStream is the streaming form of FindAllMatcherWith. Each file is
+// matched and given to `fn` as soon as it is found, with the walk paused
+// until `fn` returns, providing natural back-pressure. When `fn` returns
+// false, the walk stops and FindAllStream returns without error.
+//
+// No file lists are accumulated and the MaxFileCount does not apply, so
+// memory use remains flat regardless of the size of the trees walked. Walk
+// errors are streamed as results with a *WalkError Err and, when the
+// FindOptions.ErrorPolicy is StopOnError, the first one is also returned.
+// Note that with a FindOptions.Order, the walk completes before the first
+// file is matched
+func FindAllStream(targets []string, options FindOptions, matcher FindAllMatcherFn, fn FindResultFn) (err error) {
+	err = findAllStream(nil, targets, options, matcher, fn)
+	return
+}
+
+// findAllStream is FindAllStream with a `done` channel which stops the walk
+// early when closed
+func findAllStream(done <-chan struct{}, targets []string, options FindOptions, matcher FindAllMatcherFn, fn FindResultFn) (err error) {
+	var stopped bool
+	f := &cFinder{options: options, walker: newWalker(options.FS), matcher: matcher, done: done}
+	f.emit = func(file string, matched bool, ee error) (err error) {
+		if stopped = stopped || !fn(FindResult{File: file, Matched: matched, Err: ee}); stopped {
+			err = errStreamStopped
+		}
+		return
+	}
+	err = f.run(targets, func(we *WalkError) (stop bool) {
+		stopped = stopped || !fn(FindResult{File: we.Path, Err: we})
+		stop = stopped
+		return
+	})
+	if errors.Is(err, errStreamStopped) {
+		err = nil
+	}
+	return
+}
+
+// FindAllChan is the channel form of FindAllStream, running the search in a
+// separate goroutine and sending each FindResult on the unbuffered channel
+// returned. Cancelling the `ctx` stops the search early and the channel is
+// closed once the search is done
+func FindAllChan(ctx context.Context, targets []string, options FindOptions, matcher FindAllMatcherFn) (results <-chan FindResult) {
+	ch := make(chan FindResult)
+	go func() {
+		defer close(ch)
+		_ = findAllStream(ctx.Done(), targets, options, matcher, func(result FindResult) (proceed bool) {
+			select {
+			case <-ctx.Done():
+				return false
+			case ch <- result:
+				return ctx.Err() == nil
+			}
+		})
+	}()
+	results = ch
+	return
+}
@@ -0,0 +1,160 @@
+// Copyright (c) 2024  The Go-Curses Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package replace
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestFindersStream(t *testing.T) {
+	Convey("FindAllStream", t, func() {
+		dir := tMakeTree(t, "a.txt", "b.txt", "c.txt", "sub/b.txt")
+		matcher := func(data []byte) (matched bool) {
+			return strings.HasSuffix(string(data), "b.txt")
+		}
+
+		Convey("yields every file in order", func() {
+			var results []FindResult
+			err := FindAllStream([]string{dir}, FindOptions{Recurse: true}, matcher, func(result FindResult) (proceed bool) {
+				results = append(results, result)
+				return true
+			})
+			So(err, ShouldBeNil)
+			So(results, ShouldResemble, []FindResult{
+				{File: filepath.Join(dir, "a.txt")},
+				{File: filepath.Join(dir, "b.txt"), Matched: true},
+				{File: filepath.Join(dir, "c.txt")},
+				{File: filepath.Join(dir, "sub", "b.txt"), Matched: true},
+			})
+		})
+
+		Convey("stops early", func() {
+			var seen, read int
+			err := FindAllStream([]string{dir}, FindOptions{Recurse: true}, func(data []byte) (matched bool) {
+				read += 1
+				return matcher(data)
+			}, func(result FindResult) (proceed bool) {
+				seen += 1
+				return !result.Matched
+			})
+			So(err, ShouldBeNil)
+			So(seen, ShouldEqual, 2)
+			So(read, ShouldEqual, 2)
+		})
+
+		Convey("MaxFileCount does not apply", func() {
+			orig := MaxFileCount
+			defer func() { MaxFileCount = orig }()
+			MaxFileCount = 1
+			var seen int
+			err := FindAllStream([]string{dir}, FindOptions{Recurse: true}, matcher, func(result FindResult) (proceed bool) {
+				seen += 1
+				So(result.Err, ShouldBeNil)
+				return true
+			})
+			So(err, ShouldBeNil)
+			So(seen, ShouldEqual, 4)
+		})
+
+		Convey("walk errors are streamed", func() {
+			missing := filepath.Join(dir, "missing")
+			var errs []string
+			err := FindAllStream([]string{missing, dir}, FindOptions{Recurse: true}, matcher, func(result FindResult) (proceed bool) {
+				var we *WalkError
+				if errors.As(result.Err, &we) {
+					errs = append(errs, result.File)
+				}
+				return true
+			})
+			So(err, ShouldBeNil)
+			So(errs, ShouldResemble, []string{missing})
+
+			err = FindAllStream([]string{dir, missing}, FindOptions{Recurse: true, ErrorPolicy: StopOnError}, matcher, func(result FindResult) (proceed bool) {
+				return true
+			})
+			So(errors.Is(err, os.ErrNotExist), ShouldBeTrue)
+		})
+
+		Convey("stopping at a walk error stops the walk", func() {
+			var seen, visited int
+			err := FindAllStream([]string{filepath.Join(dir, "missing"), dir}, FindOptions{
+				Recurse: true,
+				Filter: func(candidate FileCandidate) (ok bool) {
+					visited += 1
+					return true
+				},
+			}, matcher, func(result FindResult) (proceed bool) {
+				seen += 1
+				return false
+			})
+			So(err, ShouldBeNil)
+			So(seen, ShouldEqual, 1)
+			So(visited, ShouldEqual, 0)
+		})
+	})
+
+	Convey("FindAllChan", t, func() {
+		dir := tMakeTree(t, "a.txt", "b.txt", "c.txt")
+		matcher := func(data []byte) (matched bool) {
+			return strings.HasSuffix(string(data), "b.txt")
+		}
+
+		Convey("receives every result", func() {
+			var files []string
+			for result := range FindAllChan(context.Background(), []string{dir}, FindOptions{Recurse: true}, matcher) {
+				files = append(files, filepath.Base(result.File))
+			}
+			So(files, ShouldResemble, []string{"a.txt", "b.txt", "c.txt"})
+		})
+
+		Convey("cancelling stops the search", func() {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			var files []string
+			for result := range FindAllChan(ctx, []string{dir}, FindOptions{Recurse: true}, matcher) {
+				files = append(files, filepath.Base(result.File))
+				cancel()
+			}
+			// a result already waiting to be sent can still be received
+			So(files[0], ShouldEqual, "a.txt")
+			So(len(files), ShouldBeLessThan, 3)
+		})
+
+		Convey("cancelling stops the walk", func() {
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+			var visited int
+			var results int
+			for range FindAllChan(ctx, []string{dir}, FindOptions{
+				Recurse: true,
+				Filter: func(candidate FileCandidate) (ok bool) {
+					visited += 1
+					return true
+				},
+			}, matcher) {
+				results += 1
+			}
+			So(results, ShouldEqual, 0)
+			So(visited, ShouldEqual, 0)
+		})
+	})
+}
@@ -17,27 +17,112 @@ package replace
 import (
 	"errors"
 	"fmt"
+	"io/fs"
 	"math"
-	"os"
+	"path/filepath"
 	"regexp"
+	"slices"
 	"strings"
+	"unicode/utf8"
 
 	"github.com/go-corelibs/globs"
 	"github.com/go-corelibs/path"
 )
 
 var (
-	ErrLargeFile    = errors.New("large file")
-	ErrBinaryFile   = errors.New("binary file")
-	ErrTooManyFiles = fmt.Errorf("too many files")
+	ErrLargeFile     = errors.New("large file")
+	ErrBinaryFile    = errors.New("binary file")
+	ErrTooManyFiles  = fmt.Errorf("too many files")
+	ErrSymlinkLoop   = errors.New("symlink loop")
+	ErrSymlinkUnsafe = errors.New("symlink loops cannot be detected")
+
+	errMmapUnsupported = errors.New("memory mapping not supported")
 )
 
 var (
 	MaxFileSize  = int64(math.Round(1024.0 * 1024.0 * 5.0))
 	MaxFileCount = 1000000
+	// MmapFileSize is the size at which the finders memory map files for
+	// matching instead of reading them into memory, where supported. Zero
+	// or less disables memory mapping, which is the default. Only enable
+	// this with matchers which do not keep any of the `data` they are given
+	// and when the files searched are not truncated during the search,
+	// which crashes the process with SIGBUS
+	MmapFileSize int64
+)
+
+// WalkError describes a problem encountered while walking the filesystem,
+// such as a directory which cannot be read or a broken symlink
+type WalkError struct {
+	// Path is the file or directory with the problem
+	Path string
+	// Err is the underlying error
+	Err error
+}
+
+func (e *WalkError) Error() (message string) {
+	message = e.Path + ": " + e.Err.Error()
+	return
+}
+
+func (e *WalkError) Unwrap() (err error) {
+	err = e.Err
+	return
+}
+
+// WalkErrors is the list of all WalkError instances encountered during a
+// single walk of the filesystem
+type WalkErrors []*WalkError
+
+func (e WalkErrors) Error() (message string) {
+	messages := make([]string, len(e))
+	for idx, we := range e {
+		messages[idx] = we.Error()
+	}
+	message = strings.Join(messages, "; ")
+	return
+}
+
+func (e WalkErrors) Unwrap() (errs []error) {
+	for _, we := range e {
+		errs = append(errs, we)
+	}
+	return
+}
+
+// ErrorPolicy specifies what to do when a WalkError is encountered
+type ErrorPolicy uint8
+
+const (
+	// ContinueOnError reports the error and keeps walking
+	ContinueOnError ErrorPolicy = iota
+	// StopOnError reports the error and stops walking
+	StopOnError
+)
+
+// SymlinkPolicy specifies which symbolic links are followed when walking
+// directories. Files and directories reached by more than one path are only
+// found once, using the device and inode numbers where supported and the
+// resolved absolute paths of local files otherwise
+type SymlinkPolicy uint8
+
+const (
+	// SymlinkFollowFiles follows symbolic links to files and skips those to
+	// directories
+	SymlinkFollowFiles SymlinkPolicy = iota
+	// SymlinkFollowNone skips all symbolic links
+	SymlinkFollowNone
+	// SymlinkFollowAll follows all symbolic links, reporting ErrSymlinkLoop
+	// for links back to a parent directory. Links to directories within an
+	// fs.FS without device and inode numbers, such as an os.DirFS on
+	// platforms other than unix, are reported with ErrSymlinkUnsafe instead
+	// of being followed because their loops cannot be detected
+	SymlinkFollowAll
 )
 
-// FindAllMatcherFn is the function signature for custom matching of content
+// FindAllMatcherFn is the function signature for custom matching of content.
+// When the MmapFileSize is set, the `data` may be memory mapped and is then
+// only valid for the duration of the call
 type FindAllMatcherFn func(data []byte) (matched bool)
 
 // FindAllMatchingFn is the function signature for custom tracking of the
@@ -69,69 +154,545 @@ func IsIncluded(include, exclude globs.Globs, input string) (included bool) {
 }
 
 // FindAllIncluded walks the given target paths, looking for unique IsIncluded
-// files
+// files. Any problems walking the filesystem are ignored, see
+// FindAllIncludedWith for receiving them
 func FindAllIncluded(targets []string, includeHidden, noLimit, binAsText, recurse bool, include, exclude globs.Globs) (found []string) {
-	unique := make(map[string]struct{})
-	check := func(target string) (allowed bool) {
-		if _, present := unique[target]; present {
-			return
-		} else if !includeHidden && path.IsHidden(target) {
+	found, _ = FindAllIncludedWith(targets, FindOptions{
+		IncludeHidden: includeHidden,
+		NoLimit:       noLimit,
+		BinAsText:     binAsText,
+		Recurse:       recurse,
+		Include:       include,
+		Exclude:       exclude,
+	})
+	return
+}
+
+// FindAllIncludedWith is the FindOptions form of FindAllIncluded, reporting
+// each WalkError to the FindOptions.OnError func (if set) and returning them
+// as WalkErrors. When the FindOptions.ErrorPolicy is StopOnError, the walk
+// stops at the first problem and the WalkError is returned as-is
+func FindAllIncludedWith(targets []string, options FindOptions) (found []string, err error) {
+	err = newIncluder(options, func(file string) (stop bool) {
+		found = append(found, file)
+		return
+	}).run(targets)
+	return
+}
+
+// cIncluder is the FindAllIncludedWith walking state
+type cIncluder struct {
+	options FindOptions
+	walker  walker
+	yield   func(file string) (stop bool)   // called with each file found
+	halt    func(we *WalkError) (stop bool) // called with each problem, true stops the walk
+	done    <-chan struct{}                 // closed to stop the walk early
+	ordered []cFoundFile                    // files held for sorting
+	unique  map[string]struct{}             // paths already checked
+	files   map[cFileID]struct{}            // files already found
+	dirs    map[cFileID]struct{}            // directories already walked
+	errs    WalkErrors
+	err     error
+}
+
+func newIncluder(options FindOptions, yield func(file string) (stop bool)) (c *cIncluder) {
+	c = &cIncluder{
+		options: options,
+		walker:  newWalker(options.FS),
+		yield:   yield,
+		unique:  make(map[string]struct{}),
+		files:   make(map[cFileID]struct{}),
+		dirs:    make(map[cFileID]struct{}),
+	}
+	return
+}
+
+// run walks the `targets`, returning the WalkErrors encountered or the first
+// WalkError when stopping on errors
+func (c *cIncluder) run(targets []string) (err error) {
+	if !c.walk(targets, nil, 0) && len(c.errs) > 0 {
+		c.err = c.errs
+	}
+	if c.options.Order != OrderDefault {
+		sortFoundFiles(c.ordered, c.options.Order)
+		for _, found := range c.ordered {
+			if c.yield(found.path) {
+				break
+			}
+		}
+	}
+	err = c.err
+	return
+}
+
+// report records the problem with the `target`, returning true if the walk
+// is to stop
+func (c *cIncluder) report(target string, ee error) (stop bool) {
+	we := &WalkError{Path: target, Err: ee}
+	if c.options.OnError != nil {
+		c.options.OnError(we)
+	}
+	if stop = c.options.ErrorPolicy == StopOnError; stop {
+		c.err = we
+	} else {
+		c.errs = append(c.errs, we)
+	}
+	if c.halt != nil && c.halt(we) {
+		stop = true
+	}
+	return
+}
+
+// cancelled returns true if the walk is to stop early
+func (c *cIncluder) cancelled() (cancelled bool) {
+	select {
+	case <-c.done:
+		cancelled = true
+	default:
+	}
+	return
+}
+
+// check returns true if the `target` path has not been checked before, is
+// not hidden (unless included) and IsIncluded
+func (c *cIncluder) check(target string) (allowed bool) {
+	if _, present := c.unique[target]; present {
+		return
+	} else if !c.options.IncludeHidden && path.IsHidden(target) {
+		return
+	}
+	allowed = IsIncluded(c.options.Include, c.options.Exclude, target)
+	c.unique[target] = struct{}{} // don't check this target again
+	return
+}
+
+// add yields the `file` found at the `depth` given, unless it is filtered out
+// or the same file was already found by another path. Files are held for
+// sorting instead when there is a FindOrder
+func (c *cIncluder) add(file string, info fs.FileInfo, depth int) (stop bool) {
+	if c.options.Filter != nil && !c.options.Filter(FileCandidate{Path: file, Info: info, fsys: c.options.FS}) {
+		return
+	}
+	if id, ok := c.walker.identity(file, info); ok {
+		if _, present := c.files[id]; present {
 			return
 		}
-		allowed = IsIncluded(include, exclude, target)
-		unique[target] = struct{}{} // don't check this target again
+		c.files[id] = struct{}{}
+	}
+	if c.options.Order != OrderDefault {
+		c.ordered = append(c.ordered, cFoundFile{path: file, info: info, depth: depth})
 		return
 	}
+	stop = c.yield(file)
+	return
+}
+
+// walk processes the `targets` given, `parents` are the identities of the
+// directories leading to the `targets` and `depth` is the number of those
+// directories
+func (c *cIncluder) walk(targets []string, parents []cFileID, depth int) (stop bool) {
 	for _, target := range targets {
-		if path.IsFile(target) {
+		if c.cancelled() {
+			return true
+		}
+		info, ee := c.walker.stat(target)
+		if ee != nil {
+			if c.report(target, ee) {
+				return true
+			}
+			continue
+		} else if !info.IsDir() {
 			// process file path
-			if check(target) {
-				found = append(found, target)
+			if depth >= c.options.MinDepth && c.check(target) && c.add(target, info, depth) {
+				return true
+			}
+			continue
+		} else if !c.options.Recurse {
+			continue
+		} else if c.options.MaxDepth > 0 && depth >= c.options.MaxDepth {
+			continue
+		}
+
+		// process dir path
+		id, identified := c.walker.identity(target, info)
+		if identified {
+			if slices.Contains(parents, id) {
+				if c.report(target, ErrSymlinkLoop) {
+					return true
+				}
+				continue
+			} else if _, present := c.dirs[id]; present {
+				// already walked by another path
+				continue
+			}
+			c.dirs[id] = struct{}{}
+		}
+
+		files, ee := c.walker.listFiles(target, c.options.IncludeHidden)
+		if ee != nil {
+			// listing the subdirectories would fail the same way
+			if c.report(target, ee) {
+				return true
+			}
+			continue
+		}
+		var linked []string
+		for _, file := range files {
+			if c.cancelled() {
+				return true
+			}
+			var dir bool
+			if dir, stop = c.entry(file, depth+1); stop {
+				return
+			} else if dir {
+				linked = append(linked, file)
+			}
+		}
+
+		dirs, ee := c.walker.listDirs(target, c.options.IncludeHidden)
+		if ee != nil && c.report(target, ee) {
+			return true
+		}
+		if identified {
+			parents = append(parents, id)
+		}
+		if c.walk(c.prune(append(dirs, linked...)), parents, depth+1) {
+			return true
+		}
+		if identified {
+			parents = parents[:len(parents)-1]
+		}
+	}
+	return
+}
+
+// prune returns the `dirs` which do not match any of the Prune globs
+func (c *cIncluder) prune(dirs []string) (kept []string) {
+	if len(c.options.Prune) == 0 {
+		kept = dirs
+		return
+	}
+	for _, dir := range dirs {
+		if !c.options.Prune.Match(dir) && !c.options.Prune.Match(filepath.Base(dir)) {
+			kept = append(kept, dir)
+		}
+	}
+	return
+}
+
+// entry processes one of the non-directory entries of a directory, which can
+// be a symlink to a directory that is to be walked when `dir` is true
+func (c *cIncluder) entry(file string, depth int) (dir, stop bool) {
+	info, ee := c.walker.lstat(file)
+	if ee != nil {
+		stop = c.report(file, ee)
+		return
+	}
+
+	if info.Mode()&fs.ModeSymlink != 0 {
+		if c.options.Symlinks == SymlinkFollowNone {
+			return
+		} else if info, ee = c.walker.stat(file); ee != nil {
+			// broken symlink
+			if c.check(file) {
+				stop = c.report(file, ee)
 			}
-		} else if recurse && path.IsDir(target) {
-			// process dir path
-			files, _ := path.ListFiles(target, includeHidden)
-			for _, file := range files {
-				if check(file) {
-					found = append(found, file)
+			return
+		} else if info.IsDir() {
+			if dir = c.options.Symlinks == SymlinkFollowAll; dir {
+				if _, identified := c.walker.identity(file, info); !identified {
+					dir, stop = false, c.report(file, ErrSymlinkUnsafe)
 				}
 			}
-			dirs, _ := path.ListDirs(target, includeHidden)
-			more := FindAllIncluded(dirs, includeHidden, noLimit, binAsText, recurse, include, exclude)
-			found = append(found, more...)
+			return
 		}
 	}
+
+	if info.IsDir() {
+		// directories are never files, even when a walker can't tell it
+		// was reached by a symlink
+		return
+	} else if depth >= c.options.MinDepth && c.check(file) {
+		stop = c.add(file, info, depth)
+	}
 	return
 }
 
+// FindOptions is the structured form of the many boolean arguments accepted
+// by the FindAllMatcher family of functions, for use with FindAllMatcherWith
+type FindOptions struct {
+	// IncludeHidden includes files and directories starting with a period
+	IncludeHidden bool
+	// NoLimit disables the MaxFileSize check
+	NoLimit bool
+	// BinAsText processes binary files as if they were text
+	BinAsText bool
+	// Recurse descends into directories
+	Recurse bool
+	// Include and Exclude constrain the files found, see IsIncluded
+	Include globs.Globs
+	Exclude globs.Globs
+	// Archives descends into .zip, .jar, .tar, .tar.gz, .tgz and .gz files,
+	// applying Include and Exclude to the member paths and reporting each
+	// member with JoinArchivePath. Archive files themselves are only subject
+	// to the Exclude globs
+	Archives bool
+	// FS is the filesystem to find files within, when nil the local
+	// filesystem is used
+	FS fs.FS
+	// ErrorPolicy specifies whether to stop or continue walking when there
+	// are problems reading the filesystem
+	ErrorPolicy ErrorPolicy
+	// OnError is called with each WalkError as it happens
+	OnError func(err *WalkError)
+	// Symlinks specifies which symbolic links found within directories are
+	// followed, symbolic links given as targets are always followed
+	Symlinks SymlinkPolicy
+	// Prune skips walking any directories with a path or name matching one of
+	// the globs, directories given as targets are never pruned
+	Prune globs.Globs
+	// MaxDepth limits how many directories deep to walk, files directly
+	// within a target directory are at a depth of one. Zero is unlimited
+	MaxDepth int
+	// MinDepth excludes files less than the number of directories deep, with
+	// files given as targets being at a depth of zero
+	MinDepth int
+	// Filter excludes files for which the predicate is false, see FileAll
+	// and the other File predicates. Archive members are not filtered
+	Filter FilePredicate
+	// Order specifies the order in which files are reported, see FindOrder
+	Order FindOrder
+	// Binary specifies how binary files are handled when BinAsText is false,
+	// see BinaryMode
+	Binary BinaryMode
+	// Detector decides which files are binary, when nil the
+	// DefaultBinaryDetector is used
+	Detector BinaryDetector
+	// Locator finds the offsets of each match within binary files when the
+	// Binary mode is BinarySearch, when nil the matcher func is used and no
+	// offsets are reported
+	Locator FindAllLocatorFn
+}
+
 // FindAllMatcher uses FindAllIncluded to derive a list of `files` and
 // a list of `matches` (using the `matcher` func), returning ErrTooManyFiles
 // if the total number of files exceeds the MaxFileCount. While performing the
 // find process, calls the given `fn` to report progress and the state of each
 // file processed
 func FindAllMatcher(targets []string, includeHidden, noLimit, binAsText, recurse bool, include, exclude globs.Globs, fn FindAllMatchingFn, matcher FindAllMatcherFn) (files, matches []string, err error) {
+	files, matches, err = FindAllMatcherWith(targets, FindOptions{
+		IncludeHidden: includeHidden,
+		NoLimit:       noLimit,
+		BinAsText:     binAsText,
+		Recurse:       recurse,
+		Include:       include,
+		Exclude:       exclude,
+	}, fn, matcher)
+	return
+}
+
+// FindAllMatcherWith is the FindOptions form of FindAllMatcher. Each WalkError
+// is reported to the FindOptions.OnError func and the `fn` given, without
+// being included in the `files` list. When the FindOptions.ErrorPolicy is
+// StopOnError, the first WalkError is returned. Binary files matched with the
+// BinarySearch mode are reported to `fn` as matched with a *BinaryMatch error
+// and are included in the `files` list but not the `matches` list
+func FindAllMatcherWith(targets []string, options FindOptions, fn FindAllMatchingFn, matcher FindAllMatcherFn) (files, matches []string, err error) {
 	if fn == nil {
 		fn = func(file string, matched bool, err error) {}
 	}
-	for _, target := range FindAllIncluded(targets, includeHidden, noLimit, binAsText, recurse, include, exclude) {
-		files = append(files, target)
-		if len(files) > MaxFileCount {
+	f := &cFinder{options: options, walker: newWalker(options.FS), matcher: matcher, limited: true}
+	f.emit = func(file string, matched bool, ee error) (err error) {
+		if files = append(files, file); len(files) > MaxFileCount {
 			err = ErrTooManyFiles
 			return
 		}
+		if matched && !errors.Is(ee, ErrBinaryMatch) {
+			matches = append(matches, file)
+		}
+		fn(file, matched, ee)
+		return
+	}
+	err = f.run(targets, func(we *WalkError) (stop bool) {
+		fn(we.Path, false, we)
+		return
+	})
+	return
+}
+
+// cFinder is the state of the FindAllMatcher process, walking the targets and
+// emitting the results of each file as it is matched
+type cFinder struct {
+	options FindOptions
+	walker  walker
+	matcher FindAllMatcherFn
+	emit    func(file string, matched bool, ee error) (err error)
+	done    <-chan struct{} // closed to stop the walk early
+	limited bool            // the MaxFileCount applies
+	count   int             // number of files emitted
+}
+
+// run walks the `targets`, matching each file found and calling `report`
+// with each WalkError, stopping the walk when `report` returns true
+func (f *cFinder) run(targets []string, report func(we *WalkError) (stop bool)) (err error) {
+	included := f.options
+	if f.options.Archives {
+		// archives are only constrained by the excludes, the includes are
+		// applied to the archive members
+		included.Include = nil
+	}
+	c := newIncluder(included, func(target string) (stop bool) {
+		if f.options.Archives && IsArchive(target) {
+			err = f.archive(target)
+		} else if IsIncluded(f.options.Include, nil, target) {
+			err = f.file(target)
+		}
+		stop = err != nil
+		return
+	})
+	c.halt, c.done = report, f.done
+	walkErr := c.run(targets)
+	if err == nil && f.options.ErrorPolicy == StopOnError {
+		// otherwise already reported
+		err = walkErr
+	}
+	return
+}
+
+// detector returns the BinaryDetector to use
+func (f *cFinder) detector() (detector BinaryDetector) {
+	if detector = f.options.Detector; detector == nil {
+		detector = DefaultBinaryDetector
+	}
+	return
+}
+
+// sampleSize returns the number of leading bytes to sample from each file,
+// enough for both the detector and for detecting the text Encoding
+func (f *cFinder) sampleSize() (size int) {
+	size = max(f.detector().SampleSize(), DefaultBinaryDetector.SampleSize())
+	return
+}
+
+// sample returns the leading `head` bytes of the `target` file and whether
+// the file is detected as text
+func (f *cFinder) sample(target string) (head []byte, text bool) {
+	head = f.walker.sample(target, f.sampleSize())
+	text = f.isText(target, head)
+	return
+}
+
+// isText returns true if the `name` file, starting with the `data` given, is
+// not detected as binary
+func (f *cFinder) isText(name string, data []byte) (text bool) {
+	detector := f.detector()
+	text = !detector.IsBinary(name, data[:min(len(data), detector.SampleSize())])
+	return
+}
+
+// full returns true if the MaxFileCount has been reached
+func (f *cFinder) full() (full bool) {
+	full = f.limited && f.count >= MaxFileCount
+	return
+}
+
+// track emits the result of processing the `file`
+func (f *cFinder) track(file string, matched bool, ee error) (err error) {
+	f.count += 1
+	err = f.emit(file, matched, ee)
+	return
+}
+
+func (f *cFinder) file(target string) (err error) {
+	if f.full() {
+		// don't bother reading the file
+		err = f.track(target, false, nil)
+		return
+	}
+	var ee error
+	var data []byte
+	var matched bool
+	var size int64
+	var head []byte
+	var text bool
+	var info fs.FileInfo
+	release := func() {}
+	if info, ee = f.walker.stat(target); ee != nil {
+		// reported as the file's problem
+	} else if !info.Mode().IsRegular() {
+		// pipes, devices and sockets can block forever when read
+		ee = ErrBinaryFile
+	} else if size = info.Size(); !f.options.NoLimit && size > MaxFileSize {
+		ee = ErrLargeFile
+	} else if head, text = f.sample(target); text || f.options.BinAsText {
+		if data, release, ee = f.read(target, size); ee == nil {
+			if text {
+				// matchers always work with UTF-8 text
+				data = decodeMatcherData(head, data)
+			}
+			matched = f.matcher(data)
+		}
+	} else if f.options.Binary != BinarySearch {
+		ee = ErrBinaryFile
+	} else if data, release, ee = f.read(target, size); ee == nil {
+		matched, ee = f.binary(target, data)
+	}
+	release()
+	err = f.track(target, matched, ee)
+	return
+}
+
+// read returns the content of the `target` file, memory mapped when the
+// `size` is at least the MmapFileSize. The `release` func must be called once
+// the `data` is no longer needed
+func (f *cFinder) read(target string, size int64) (data []byte, release func(), err error) {
+	if MmapFileSize > 0 && size >= MmapFileSize {
+		data, release, err = f.walker.mapFile(target)
+		return
+	}
+	release = func() {}
+	data, err = f.walker.readFile(target)
+	return
+}
+
+func (f *cFinder) archive(target string) (err error) {
+	limit := MaxFileSize
+	if f.options.NoLimit {
+		limit = 0
+	}
+	if ee := walkArchive(f.walker, target, limit, func(entry archiveEntry) (stop bool) {
+		if !f.options.IncludeHidden && isHiddenMember(entry.name) {
+			return
+		} else if !IsIncluded(f.options.Include, f.options.Exclude, entry.name) {
+			return
+		}
+		member := JoinArchivePath(target, entry.name)
+		if f.full() {
+			err = f.track(member, false, nil)
+			return true
+		}
 		var ee error
 		var data []byte
 		var matched bool
-		if !noLimit && path.FileSize(target) > MaxFileSize {
+		if !f.options.NoLimit && entry.size > MaxFileSize {
 			ee = ErrLargeFile
-		} else if !binAsText && !path.IsPlainText(target) {
-			ee = ErrBinaryFile
-		} else if data, ee = os.ReadFile(target); ee == nil {
-			if matched = matcher(data); matched {
-				matches = append(matches, target)
+		} else if data, ee = entry.read(); ee == nil {
+			if text := f.isText(member, data); text || f.options.BinAsText {
+				if text {
+					data = decodeMatcherData(data[:min(len(data), f.sampleSize())], data)
+				}
+				matched = f.matcher(data)
+			} else if f.options.Binary != BinarySearch {
+				ee = ErrBinaryFile
+			} else {
+				matched, ee = f.binary(member, data)
 			}
 		}
-		fn(target, matched, ee)
+		err = f.track(member, matched, ee)
+		return err != nil
+	}); ee != nil && err == nil {
+		// the archive itself could not be read
+		err = f.track(target, false, ee)
 	}
 	return
 }
@@ -150,39 +711,47 @@ func FindAllMatchingRegexp(search *regexp.Regexp, targets []string, includeHidde
 // matcher func which uses `search.Match` on each line of each file to filter
 // the `matches` list
 func FindAllMatchingRegexpLines(search *regexp.Regexp, targets []string, includeHidden, noLimit, binAsText, recurse bool, include, exclude globs.Globs, fn FindAllMatchingFn) (files, matches []string, err error) {
-	files, matches, err = FindAllMatcher(targets, includeHidden, noLimit, binAsText, recurse, include, exclude, fn, func(data []byte) (matched bool) {
-		lines := strings.Split(string(data), "\n")
-		last := len(lines) - 1
-		for idx, line := range lines {
-			if idx < last {
-				line += "\n"
-			}
-			if matched = search.MatchString(line); matched {
-				return
-			}
-		}
-		return
-	})
+	files, matches, err = FindAllMatcher(targets, includeHidden, noLimit, binAsText, recurse, include, exclude, fn, regexpLinesMatcher(search))
 	return
 }
 
 // FindAllMatchingString is a wrapper around FindAllMatcher with a custom
-// matcher func which uses [strings.Contains] to filter the `matches` list
+// matcher func which uses [bytes.Contains] to filter the `matches` list
 func FindAllMatchingString(search string, targets []string, includeHidden, noLimit, binAsText, recurse bool, include, exclude globs.Globs, fn FindAllMatchingFn) (files, matches []string, err error) {
-	files, matches, err = FindAllMatcher(targets, includeHidden, noLimit, binAsText, recurse, include, exclude, fn, func(data []byte) (matched bool) {
-		matched = strings.Contains(string(data), search)
-		return
-	})
+	files, matches, err = FindAllMatcher(targets, includeHidden, noLimit, binAsText, recurse, include, exclude, fn, stringMatcher(search))
 	return
 }
 
 // FindAllMatchingStringInsensitive is a wrapper around FindAllMatcher with a
-// custom matcher func which uses [strings.Contains], in a case-insensitive
-// way, to filter the `matches` list
+// custom matcher func which uses a prepared case-folding search, with Unicode
+// simple case folding, to filter the `matches` list
 func FindAllMatchingStringInsensitive(search string, targets []string, includeHidden, noLimit, binAsText, recurse bool, include, exclude globs.Globs, fn FindAllMatchingFn) (files, matches []string, err error) {
-	files, matches, err = FindAllMatcher(targets, includeHidden, noLimit, binAsText, recurse, include, exclude, fn, func(data []byte) (matched bool) {
-		matched = strings.Contains(strings.ToLower(string(data)), strings.ToLower(search))
-		return
-	})
+	files, matches, err = FindAllMatcher(targets, includeHidden, noLimit, binAsText, recurse, include, exclude, fn, stringInsensitiveMatcher(search))
+	return
+}
+
+// decodeMatcherData returns the given `data` transcoded to UTF-8 when the
+// `head` sampled from the start of it detects as another Encoding, otherwise
+// or when the `data` fails to decode, `data` is returned as-is
+func decodeMatcherData(head, data []byte) (decoded []byte) {
+	enc := DetectEncoding(head)
+	if len(head) < len(data) && (enc.Charset == CharsetLatin1 || enc.Charset == CharsetWindows1252) {
+		// the sample may end part way through a UTF-8 character
+		for idx := len(head) - 1; idx >= max(0, len(head)-utf8.UTFMax); idx-- {
+			if utf8.RuneStart(head[idx]) {
+				if !utf8.FullRune(head[idx:]) {
+					enc = DetectEncoding(head[:idx])
+				}
+				break
+			}
+		}
+	}
+	if !enc.IsUTF8() {
+		if text, err := enc.Decode(data); err == nil {
+			decoded = []byte(text)
+			return
+		}
+	}
+	decoded = data
 	return
 }
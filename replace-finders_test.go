@@ -15,17 +15,57 @@
 package replace
 
 import (
+	"errors"
+	"io/fs"
 	"os"
+	"path/filepath"
 	"regexp"
 	"strings"
 	"sync"
 	"testing"
+	"testing/fstest"
 
 	. "github.com/smartystreets/goconvey/convey"
 
 	"github.com/go-corelibs/globs"
 )
 
+// tFailingFS is a fstest.MapFS which can't read the `fail` directory
+type tFailingFS struct {
+	fstest.MapFS
+	fail string
+}
+
+func (f tFailingFS) ReadDir(name string) (entries []fs.DirEntry, err error) {
+	if name == f.fail {
+		err = &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrPermission}
+		return
+	}
+	entries, err = f.MapFS.ReadDir(name)
+	return
+}
+
+// tPathWalker is a local walker which only has the pathIdentity fallback, like
+// on platforms other than unix
+type tPathWalker struct {
+	cOsWalker
+}
+
+func (tPathWalker) identity(name string, _ fs.FileInfo) (id cFileID, ok bool) {
+	id, ok = pathIdentity(name)
+	return
+}
+
+// tAnonWalker is a local walker which can't identify anything, like an fs.FS
+// walker on platforms other than unix
+type tAnonWalker struct {
+	cOsWalker
+}
+
+func (tAnonWalker) identity(_ string, _ fs.FileInfo) (id cFileID, ok bool) {
+	return
+}
+
 func TestFinders(t *testing.T) {
 	Convey("IsIncluded", t, func() {
 		var err error
@@ -85,6 +125,36 @@ func TestFinders(t *testing.T) {
 		So(len(matches), ShouldEqual, 2)
 	})
 
+	Convey("FindAllMatchingString with encoded text", t, func() {
+		dir := t.TempDir()
+		utf16 := filepath.Join(dir, "utf16.txt")
+		latin1 := filepath.Join(dir, "latin1.txt")
+		So(os.WriteFile(utf16, []byte{'c', 0, 'a', 0, 'f', 0, 0xe9, 0, '\n', 0}, 0644), ShouldBeNil)
+		So(os.WriteFile(latin1, []byte("caf\xe9\n"), 0644), ShouldBeNil)
+		found, matches, err := FindAllMatchingString("café", []string{dir}, false, false, false, true, nil, nil, nil)
+		So(err, ShouldBeNil)
+		So(len(found), ShouldEqual, 2)
+		So(len(matches), ShouldEqual, 2)
+
+		Convey("UTF-8 with a stray byte is not transcoded", func() {
+			stray := filepath.Join(dir, "stray.txt")
+			So(os.WriteFile(stray, []byte("naïve café code\nstray byte: \xff\n"), 0644), ShouldBeNil)
+			_, matches, err = FindAllMatchingString("café", []string{stray}, false, false, false, false, nil, nil, nil)
+			So(err, ShouldBeNil)
+			So(matches, ShouldResemble, []string{stray})
+		})
+
+		Convey("the encoding is detected from the sample", func() {
+			// the sample ends part way through the é and the stray bytes
+			// following it are never sampled
+			sampled := filepath.Join(dir, "sampled.txt")
+			So(os.WriteFile(sampled, []byte(strings.Repeat("x", 8188)+"café \xff\xff\n"), 0644), ShouldBeNil)
+			_, matches, err = FindAllMatchingString("café", []string{sampled}, false, false, false, false, nil, nil, nil)
+			So(err, ShouldBeNil)
+			So(matches, ShouldResemble, []string{sampled})
+		})
+	})
+
 	Convey("FindAllMatcher", t, func() {
 		targets := []string{"_testing"}
 		origMaxFileSize := MaxFileSize
@@ -158,4 +228,306 @@ func TestFinders(t *testing.T) {
 
 	})
 
+	Convey("Walk errors", t, func() {
+		dir := tMakeTree(t, "one.txt", "sub/two.txt")
+		broken := filepath.Join(dir, "sub", "broken.txt")
+		missing := filepath.Join(dir, "missing")
+		So(os.Symlink(filepath.Join(dir, "nowhere"), broken), ShouldBeNil)
+
+		Convey("FindAllIncluded ignores them", func() {
+			found := FindAllIncluded([]string{dir, missing}, false, false, false, true, nil, nil)
+			So(found, ShouldResemble, []string{
+				filepath.Join(dir, "one.txt"),
+				filepath.Join(dir, "sub", "two.txt"),
+			})
+		})
+
+		Convey("FindAllIncludedWith continues", func() {
+			var reported []string
+			found, err := FindAllIncludedWith([]string{missing, dir}, FindOptions{
+				Recurse: true,
+				OnError: func(err *WalkError) {
+					reported = append(reported, err.Path)
+				},
+			})
+			So(found, ShouldHaveLength, 2)
+			So(reported, ShouldResemble, []string{missing, broken})
+			var errs WalkErrors
+			So(errors.As(err, &errs), ShouldBeTrue)
+			So(errs, ShouldHaveLength, 2)
+			So(errs[0].Path, ShouldEqual, missing)
+			So(errors.Is(err, os.ErrNotExist), ShouldBeTrue)
+			So(err.Error(), ShouldStartWith, missing+": ")
+		})
+
+		Convey("FindAllIncludedWith stops", func() {
+			found, err := FindAllIncludedWith([]string{dir, missing}, FindOptions{
+				Recurse:     true,
+				ErrorPolicy: StopOnError,
+			})
+			So(found, ShouldResemble, []string{filepath.Join(dir, "one.txt")})
+			var we *WalkError
+			So(errors.As(err, &we), ShouldBeTrue)
+			So(we.Path, ShouldEqual, broken)
+		})
+
+		Convey("excluded paths are not reported", func() {
+			exclude, _ := globs.Parse("*/broken.txt")
+			found, err := FindAllIncludedWith([]string{dir}, FindOptions{Recurse: true, Exclude: exclude})
+			So(err, ShouldBeNil)
+			So(found, ShouldHaveLength, 2)
+		})
+
+		Convey("FindAllMatcherWith reports them to the tracking func", func() {
+			var tracked []string
+			files, matches, err := FindAllMatcherWith([]string{dir}, FindOptions{Recurse: true}, func(file string, matched bool, err error) {
+				var we *WalkError
+				if errors.As(err, &we) {
+					tracked = append(tracked, file)
+				}
+			}, func(data []byte) (matched bool) {
+				return true
+			})
+			So(err, ShouldBeNil)
+			So(files, ShouldHaveLength, 2)
+			So(matches, ShouldHaveLength, 2)
+			So(tracked, ShouldResemble, []string{broken})
+
+			_, _, err = FindAllMatcherWith([]string{dir}, FindOptions{Recurse: true, ErrorPolicy: StopOnError}, nil, func(data []byte) (matched bool) {
+				return true
+			})
+			So(errors.Is(err, os.ErrNotExist), ShouldBeTrue)
+		})
+
+		Convey("unreadable directories are reported once", func() {
+			fsys := tFailingFS{
+				MapFS: fstest.MapFS{
+					"one.txt":        {Data: []byte("one")},
+					"locked/two.txt": {Data: []byte("two")},
+				},
+				fail: "locked",
+			}
+			var reported, tracked []string
+			files, _, err := FindAllMatcherWith([]string{"."}, FindOptions{
+				Recurse: true,
+				FS:      fsys,
+				OnError: func(err *WalkError) {
+					reported = append(reported, err.Path)
+				},
+			}, func(file string, matched bool, err error) {
+				if err != nil {
+					tracked = append(tracked, file)
+				}
+			}, func(data []byte) (matched bool) {
+				return true
+			})
+			So(err, ShouldBeNil)
+			So(files, ShouldResemble, []string{"one.txt"})
+			So(reported, ShouldResemble, []string{"locked"})
+			So(tracked, ShouldResemble, []string{"locked"})
+
+			_, err = FindAllIncludedWith([]string{"."}, FindOptions{Recurse: true, FS: fsys})
+			var errs WalkErrors
+			So(errors.As(err, &errs), ShouldBeTrue)
+			So(errs, ShouldHaveLength, 1)
+			So(errors.Is(err, fs.ErrPermission), ShouldBeTrue)
+		})
+
+		Convey("unreadable directories", func() {
+			if os.Getuid() == 0 {
+				// root can read anything
+				return
+			}
+			locked := filepath.Join(dir, "locked")
+			So(os.Mkdir(locked, 0000), ShouldBeNil)
+			defer os.Chmod(locked, 0755)
+			_, err := FindAllIncludedWith([]string{dir}, FindOptions{Recurse: true})
+			So(errors.Is(err, os.ErrPermission), ShouldBeTrue)
+		})
+	})
+
+	Convey("Symlink policies", t, func() {
+		dir := tMakeTree(t, "a.txt", "sub/b.txt")
+		outside := tMakeTree(t, "c.txt", "lib/d.txt")
+		So(os.Symlink(filepath.Join(dir, "a.txt"), filepath.Join(dir, "alias.txt")), ShouldBeNil)
+		So(os.Symlink(filepath.Join(outside, "c.txt"), filepath.Join(dir, "c.txt")), ShouldBeNil)
+		So(os.Symlink(filepath.Join(outside, "lib"), filepath.Join(dir, "lib")), ShouldBeNil)
+		So(os.Symlink(filepath.Join(dir, "sub"), filepath.Join(dir, "twin")), ShouldBeNil)
+		So(os.Symlink(dir, filepath.Join(dir, "sub", "up")), ShouldBeNil)
+
+		find := func(policy SymlinkPolicy) (found []string, errs WalkErrors) {
+			found, _ = FindAllIncludedWith([]string{dir}, FindOptions{
+				Recurse:  true,
+				Symlinks: policy,
+				OnError: func(err *WalkError) {
+					errs = append(errs, err)
+				},
+			})
+			return
+		}
+
+		Convey("follow files", func() {
+			found, errs := find(SymlinkFollowFiles)
+			So(errs, ShouldBeEmpty)
+			So(found, ShouldResemble, []string{
+				filepath.Join(dir, "a.txt"),
+				filepath.Join(dir, "c.txt"),
+				filepath.Join(dir, "sub", "b.txt"),
+			})
+		})
+
+		Convey("follow none", func() {
+			found, errs := find(SymlinkFollowNone)
+			So(errs, ShouldBeEmpty)
+			So(found, ShouldResemble, []string{
+				filepath.Join(dir, "a.txt"),
+				filepath.Join(dir, "sub", "b.txt"),
+			})
+		})
+
+		Convey("follow all", func() {
+			found, errs := find(SymlinkFollowAll)
+			So(found, ShouldResemble, []string{
+				filepath.Join(dir, "a.txt"),
+				filepath.Join(dir, "c.txt"),
+				filepath.Join(dir, "sub", "b.txt"),
+				filepath.Join(dir, "lib", "d.txt"),
+			})
+			So(errs, ShouldHaveLength, 1)
+			So(errs[0].Path, ShouldEqual, filepath.Join(dir, "sub", "up"))
+			So(errors.Is(errs[0], ErrSymlinkLoop), ShouldBeTrue)
+		})
+
+		Convey("follow all without device and inode numbers", func() {
+			walk := func(w walker) (found []string, errs WalkErrors) {
+				c := newIncluder(FindOptions{
+					Recurse:  true,
+					Symlinks: SymlinkFollowAll,
+					OnError: func(err *WalkError) {
+						errs = append(errs, err)
+					},
+				}, func(file string) (stop bool) {
+					found = append(found, file)
+					return
+				})
+				c.walker = w
+				_ = c.run([]string{dir})
+				return
+			}
+
+			found, errs := walk(tPathWalker{})
+			So(found, ShouldResemble, []string{
+				filepath.Join(dir, "a.txt"),
+				filepath.Join(dir, "c.txt"),
+				filepath.Join(dir, "sub", "b.txt"),
+				filepath.Join(dir, "lib", "d.txt"),
+			})
+			So(errs, ShouldHaveLength, 1)
+			So(errs[0].Path, ShouldEqual, filepath.Join(dir, "sub", "up"))
+			So(errors.Is(errs[0], ErrSymlinkLoop), ShouldBeTrue)
+
+			found, errs = walk(tAnonWalker{})
+			So(found, ShouldContain, filepath.Join(dir, "sub", "b.txt"))
+			So(found, ShouldNotContain, filepath.Join(dir, "lib", "d.txt"))
+			So(errs, ShouldHaveLength, 3)
+			for _, err := range errs {
+				So(errors.Is(err, ErrSymlinkUnsafe), ShouldBeTrue)
+			}
+		})
+
+		Convey("fs.FS walks", func() {
+			fsys := os.DirFS(dir)
+			findFS := func(policy SymlinkPolicy) (found []string, err error) {
+				found, err = FindAllIncludedWith([]string{"."}, FindOptions{Recurse: true, Symlinks: policy, FS: fsys})
+				return
+			}
+			found, err := findFS(SymlinkFollowFiles)
+			So(err, ShouldBeNil)
+			So(found, ShouldResemble, []string{"a.txt", "c.txt", "sub/b.txt"})
+			found, err = findFS(SymlinkFollowNone)
+			So(err, ShouldBeNil)
+			So(found, ShouldResemble, []string{"a.txt", "sub/b.txt"})
+			found, err = findFS(SymlinkFollowAll)
+			So(found, ShouldResemble, []string{"a.txt", "c.txt", "sub/b.txt", "lib/d.txt"})
+			So(errors.Is(err, ErrSymlinkLoop), ShouldBeTrue)
+
+			files, _, err := FindAllMatcherWith([]string{"."}, FindOptions{Recurse: true, FS: fsys}, func(file string, matched bool, err error) {
+				So(err, ShouldBeNil)
+			}, func(data []byte) (matched bool) {
+				return true
+			})
+			So(err, ShouldBeNil)
+			So(files, ShouldHaveLength, 3)
+		})
+
+		Convey("explicit targets are always followed", func() {
+			found, err := FindAllIncludedWith([]string{filepath.Join(dir, "lib"), filepath.Join(outside, "lib", "d.txt")}, FindOptions{
+				Recurse:  true,
+				Symlinks: SymlinkFollowNone,
+			})
+			So(err, ShouldBeNil)
+			So(found, ShouldResemble, []string{filepath.Join(dir, "lib", "d.txt")})
+		})
+	})
+
+	Convey("Pruning and depth limits", t, func() {
+		dir := tMakeTree(t,
+			"main.go",
+			"vendor/lib/lib.go",
+			"internal/vendor/other.go",
+			"internal/pkg/pkg.go",
+			"internal/pkg/deep/deep.go",
+		)
+		find := func(options FindOptions) (found []string) {
+			options.Recurse = true
+			found, _ = FindAllIncludedWith([]string{dir}, options)
+			for idx, file := range found {
+				found[idx], _ = filepath.Rel(dir, file)
+				found[idx] = filepath.ToSlash(found[idx])
+			}
+			return
+		}
+
+		So(find(FindOptions{}), ShouldHaveLength, 5)
+
+		prune, _ := globs.Parse("vendor")
+		So(find(FindOptions{Prune: prune}), ShouldResemble, []string{
+			"main.go",
+			"internal/pkg/pkg.go",
+			"internal/pkg/deep/deep.go",
+		})
+		prune, _ = globs.Parse(filepath.Join(dir, "vendor"))
+		So(find(FindOptions{Prune: prune}), ShouldHaveLength, 4)
+
+		So(find(FindOptions{MaxDepth: 1}), ShouldResemble, []string{"main.go"})
+		So(find(FindOptions{MaxDepth: 3}), ShouldResemble, []string{
+			"main.go",
+			"internal/pkg/pkg.go",
+			"internal/vendor/other.go",
+			"vendor/lib/lib.go",
+		})
+		So(find(FindOptions{MinDepth: 3}), ShouldResemble, []string{
+			"internal/pkg/pkg.go",
+			"internal/pkg/deep/deep.go",
+			"internal/vendor/other.go",
+			"vendor/lib/lib.go",
+		})
+		So(find(FindOptions{MinDepth: 4}), ShouldResemble, []string{
+			"internal/pkg/deep/deep.go",
+		})
+		So(find(FindOptions{MinDepth: 3, MaxDepth: 3, Prune: prune}), ShouldResemble, []string{
+			"internal/pkg/pkg.go",
+			"internal/vendor/other.go",
+		})
+
+		found, err := FindAllIncludedWith([]string{filepath.Join(dir, "vendor"), filepath.Join(dir, "main.go")}, FindOptions{
+			Recurse: true,
+			Prune:   prune,
+		})
+		So(err, ShouldBeNil)
+		So(found, ShouldHaveLength, 2)
+		found, _ = FindAllIncludedWith([]string{filepath.Join(dir, "main.go")}, FindOptions{MinDepth: 1})
+		So(found, ShouldBeEmpty)
+	})
+
 }
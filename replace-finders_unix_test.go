@@ -0,0 +1,68 @@
+// Copyright (c) 2024  The Go-Curses Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build unix
+
+package replace
+
+import (
+	"errors"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func tMakeFifo(t *testing.T, dir string) (fifo string) {
+	fifo = filepath.Join(dir, "fifo.txt")
+	if err := syscall.Mkfifo(fifo, 0644); err != nil {
+		t.Skipf("mkfifo: %v", err)
+	}
+	return
+}
+
+func TestFindersFifo(t *testing.T) {
+	Convey("named pipes are not read", t, func() {
+		dir := tMakeTree(t, "a.txt")
+		fifo := tMakeFifo(t, dir)
+
+		type result struct {
+			files, matches []string
+			err            error
+			fifoErr        error
+		}
+		done := make(chan result, 1)
+		go func() {
+			var r result
+			r.files, r.matches, r.err = FindAllMatchingString("a.txt", []string{dir}, false, false, false, true, nil, nil, func(file string, matched bool, err error) {
+				if file == fifo {
+					r.fifoErr = err
+				}
+			})
+			done <- r
+		}()
+
+		select {
+		case r := <-done:
+			So(r.err, ShouldBeNil)
+			So(r.files, ShouldResemble, []string{filepath.Join(dir, "a.txt"), fifo})
+			So(r.matches, ShouldResemble, []string{filepath.Join(dir, "a.txt")})
+			So(errors.Is(r.fifoErr, ErrBinaryFile), ShouldBeTrue)
+		case <-time.After(5 * time.Second):
+			So("the finder blocked on the named pipe", ShouldBeEmpty)
+		}
+	})
+}
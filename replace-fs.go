@@ -0,0 +1,94 @@
+// Copyright (c) 2024  The Go-Curses Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package replace
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/go-corelibs/diff"
+	"github.com/go-corelibs/globs"
+)
+
+// WritableFS is an fs.FS which also supports writing files, used to write
+// modified contents back to virtual filesystems
+type WritableFS interface {
+	fs.FS
+	// WriteFile writes data to the named file, creating it if necessary
+	WriteFile(name string, data []byte, perm fs.FileMode) (err error)
+}
+
+// DirFS returns a WritableFS for the tree of files rooted at the directory
+// given, see os.DirFS for the read-only details
+func DirFS(dir string) (fsys WritableFS) {
+	fsys = cDirFS{FS: os.DirFS(dir), dir: dir}
+	return
+}
+
+type cDirFS struct {
+	fs.FS
+	dir string
+}
+
+func (d cDirFS) WriteFile(name string, data []byte, perm fs.FileMode) (err error) {
+	if !fs.ValidPath(name) {
+		err = &fs.PathError{Op: "writefile", Path: name, Err: fs.ErrInvalid}
+		return
+	}
+	err = os.WriteFile(filepath.Join(d.dir, filepath.FromSlash(name)), data, perm)
+	return
+}
+
+// FindAllIncludedFS is like FindAllIncluded except that the targets are
+// names within the given fs.FS
+func FindAllIncludedFS(fsys fs.FS, targets []string, includeHidden, recurse bool, include, exclude globs.Globs) (found []string) {
+	found, _ = FindAllIncludedWith(targets, FindOptions{
+		IncludeHidden: includeHidden,
+		Recurse:       recurse,
+		Include:       include,
+		Exclude:       exclude,
+		FS:            fsys,
+	})
+	return
+}
+
+// ProcessFileFS is like ProcessFile except that the target is read from the
+// given fs.FS
+func ProcessFileFS(fsys fs.FS, target string, fn func(original string) (modified string, count int)) (original, modified string, count int, delta *diff.Diff, err error) {
+	var data []byte
+	if data, err = fs.ReadFile(fsys, target); err == nil {
+		original = string(data)
+		modified, count = fn(original)
+		delta = diff.New(target, original, modified)
+	}
+	return
+}
+
+// WriteFileFS overwrites the target file within the given WritableFS,
+// preserving the existing file permissions. New files are created with 0644
+// permissions
+func WriteFileFS(fsys WritableFS, target, content string) (err error) {
+	perm := fs.FileMode(0644)
+	if info, ee := fs.Stat(fsys, target); ee == nil {
+		perm = info.Mode().Perm()
+	} else if !errors.Is(ee, fs.ErrNotExist) {
+		err = ee
+		return
+	}
+	err = fsys.WriteFile(target, []byte(content), perm)
+	return
+}
@@ -0,0 +1,131 @@
+// Copyright (c) 2024  The Go-Curses Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package replace
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+	"testing/fstest"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/go-corelibs/globs"
+)
+
+// tMapFS is a WritableFS for testing purposes
+type tMapFS struct {
+	fstest.MapFS
+}
+
+func (m tMapFS) WriteFile(name string, data []byte, perm fs.FileMode) (err error) {
+	m.MapFS[name] = &fstest.MapFile{Data: data, Mode: perm}
+	return
+}
+
+func tMakeMapFS() (m tMapFS) {
+	m = tMapFS{MapFS: fstest.MapFS{
+		"README.md":            {Data: []byte("# the project\n"), Mode: 0644},
+		".hidden":              {Data: []byte("the hidden file\n"), Mode: 0600},
+		"src/main.go":          {Data: []byte("package main // the main\n"), Mode: 0644},
+		"src/.git/config":      {Data: []byte("[core]\n"), Mode: 0644},
+		"src/lib/lib.go":       {Data: []byte("package lib\n"), Mode: 0600},
+		"src/lib/data.bin":     {Data: []byte("\x00\x00the\x00\x01\x02\x00"), Mode: 0644},
+		"src/lib/utf16.txt":    {Data: []byte{'t', 0, 'h', 0, 'e', 0, '\n', 0}, Mode: 0644},
+		"vendor/pkg/vendor.go": {Data: []byte("package pkg // the vendor\n"), Mode: 0644},
+		"release.tar":          {Data: tMakeTar(tArchiveMembers), Mode: 0644},
+	}}
+	return
+}
+
+func TestFS(t *testing.T) {
+
+	Convey("FindAllIncludedFS", t, func() {
+		m := tMakeMapFS()
+		So(FindAllIncludedFS(m, []string{"."}, false, true, nil, nil), ShouldResemble, []string{
+			"README.md",
+			"release.tar",
+			"src/main.go",
+			"src/lib/data.bin",
+			"src/lib/lib.go",
+			"src/lib/utf16.txt",
+			"vendor/pkg/vendor.go",
+		})
+		So(FindAllIncludedFS(m, []string{"."}, true, false, nil, nil), ShouldBeEmpty)
+		include, _ := globs.Parse("*.go")
+		exclude, _ := globs.Parse("vendor/*")
+		So(FindAllIncludedFS(m, []string{"src", "vendor", "nope"}, true, true, include, exclude), ShouldResemble, []string{
+			"src/main.go",
+			"src/lib/lib.go",
+		})
+		So(FindAllIncludedFS(m, []string{"."}, true, true, nil, nil)[0], ShouldEqual, ".hidden")
+	})
+
+	Convey("FindAllMatcherWith FS", t, func() {
+		m := tMakeMapFS()
+		errs := map[string]error{}
+		files, matches, err := FindAllMatcherWith([]string{"."}, FindOptions{Recurse: true, Archives: true, FS: m}, func(file string, matched bool, err error) {
+			errs[file] = err
+		}, func(data []byte) (matched bool) {
+			matched = regexp.MustCompile(`\bthe\b`).Match(data)
+			return
+		})
+		So(err, ShouldBeNil)
+		So(len(files), ShouldEqual, 9)
+		So(matches, ShouldResemble, []string{
+			"README.md",
+			"src/main.go",
+			"src/lib/utf16.txt",
+			"vendor/pkg/vendor.go",
+		})
+		So(errs["src/lib/data.bin"], ShouldEqual, ErrBinaryFile)
+		So(errs["release.tar!/bin/tool"], ShouldEqual, ErrBinaryFile)
+	})
+
+	Convey("ProcessFileFS and WriteFileFS", t, func() {
+		m := tMakeMapFS()
+		original, modified, count, delta, err := ProcessFileFS(m, "src/lib/lib.go", func(original string) (modified string, count int) {
+			modified, count = String("lib", "library", original)
+			return
+		})
+		So(err, ShouldBeNil)
+		So(original, ShouldEqual, "package lib\n")
+		So(modified, ShouldEqual, "package library\n")
+		So(count, ShouldEqual, 1)
+		So(delta.Len(), ShouldEqual, 2)
+		So(WriteFileFS(m, "src/lib/lib.go", modified), ShouldBeNil)
+		So(string(m.MapFS["src/lib/lib.go"].Data), ShouldEqual, modified)
+		So(m.MapFS["src/lib/lib.go"].Mode, ShouldEqual, fs.FileMode(0600))
+		So(WriteFileFS(m, "src/new.go", "package main\n"), ShouldBeNil)
+		So(m.MapFS["src/new.go"].Mode, ShouldEqual, fs.FileMode(0644))
+		_, _, _, _, err = ProcessFileFS(m, "nope", nil)
+		So(err, ShouldNotBeNil)
+	})
+
+	Convey("DirFS", t, func() {
+		dir := t.TempDir()
+		So(os.WriteFile(filepath.Join(dir, "one.txt"), []byte("one"), 0600), ShouldBeNil)
+		d := DirFS(dir)
+		So(FindAllIncludedFS(d, []string{"."}, false, true, nil, nil), ShouldResemble, []string{"one.txt"})
+		So(WriteFileFS(d, "one.txt", "two"), ShouldBeNil)
+		data, err := os.ReadFile(filepath.Join(dir, "one.txt"))
+		So(err, ShouldBeNil)
+		So(string(data), ShouldEqual, "two")
+		So(d.WriteFile("../escape.txt", nil, 0644), ShouldNotBeNil)
+	})
+
+}
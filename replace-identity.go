@@ -0,0 +1,39 @@
+// Copyright (c) 2024  The Go-Curses Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package replace
+
+import (
+	"path/filepath"
+)
+
+// cFileID identifies a file reached by more than one path, using the device
+// and inode numbers where supported and the absolute path with all symbolic
+// links resolved otherwise
+type cFileID struct {
+	dev  uint64
+	ino  uint64
+	path string
+}
+
+// pathIdentity returns the cFileID of the local `name` file from its resolved
+// absolute path, for platforms where fileIdentity is not supported
+func pathIdentity(name string) (id cFileID, ok bool) {
+	if real, err := filepath.EvalSymlinks(name); err == nil {
+		if real, err = filepath.Abs(real); err == nil {
+			id, ok = cFileID{path: real}, true
+		}
+	}
+	return
+}
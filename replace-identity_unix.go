@@ -0,0 +1,39 @@
+// Copyright (c) 2024  The Go-Curses Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build unix
+
+package replace
+
+import (
+	"io/fs"
+	"syscall"
+)
+
+// fileIdentity returns the cFileID of the file described by `info`, `ok` is
+// false when the information is not available
+func fileIdentity(info fs.FileInfo) (id cFileID, ok bool) {
+	if st, valid := info.Sys().(*syscall.Stat_t); valid && st != nil {
+		id, ok = cFileID{dev: uint64(st.Dev), ino: uint64(st.Ino)}, true
+	}
+	return
+}
+
+// fileOwner returns the user ID owning the file described by `info`
+func fileOwner(info fs.FileInfo) (uid int, ok bool) {
+	if st, valid := info.Sys().(*syscall.Stat_t); valid && st != nil {
+		uid, ok = int(st.Uid), true
+	}
+	return
+}
@@ -0,0 +1,155 @@
+// Copyright (c) 2024  The Go-Curses Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package replace
+
+import (
+	"bytes"
+	"regexp"
+	"unicode"
+	"unicode/utf8"
+)
+
+// stringMatcher returns a FindAllMatcherFn for the `search` bytes
+func stringMatcher(search string) (matcher FindAllMatcherFn) {
+	needle := []byte(search)
+	matcher = func(data []byte) (matched bool) {
+		matched = bytes.Contains(data, needle)
+		return
+	}
+	return
+}
+
+// stringInsensitiveMatcher returns a FindAllMatcherFn for the `search` bytes,
+// in a case-insensitive way
+func stringInsensitiveMatcher(search string) (matcher FindAllMatcherFn) {
+	s := newFoldSearcher(search)
+	matcher = func(data []byte) (matched bool) {
+		matched = s.index(data) >= 0
+		return
+	}
+	return
+}
+
+// regexpLinesMatcher returns a FindAllMatcherFn using `search.Match` on each
+// line of the data, including the line's trailing newline
+func regexpLinesMatcher(search *regexp.Regexp) (matcher FindAllMatcherFn) {
+	matcher = func(data []byte) (matched bool) {
+		for {
+			idx := bytes.IndexByte(data, '\n')
+			if idx < 0 {
+				matched = search.Match(data)
+				return
+			} else if matched = search.Match(data[:idx+1]); matched {
+				return
+			}
+			data = data[idx+1:]
+		}
+	}
+	return
+}
+
+// cFoldSearcher is a prepared case-insensitive search, using Unicode simple
+// case folding
+type cFoldSearcher struct {
+	needle []byte
+	firsts string // all case forms of the first rune of the needle
+}
+
+func newFoldSearcher(search string) (s cFoldSearcher) {
+	s.needle = []byte(search)
+	if r, _ := utf8.DecodeRuneInString(search); search != "" {
+		firsts := []rune{r}
+		for f := unicode.SimpleFold(r); f != r; f = unicode.SimpleFold(f) {
+			firsts = append(firsts, f)
+		}
+		s.firsts = string(firsts)
+	}
+	return
+}
+
+// index returns the offset of the first case-insensitive match of the needle
+// within the `data`, or -1 if not present
+func (s cFoldSearcher) index(data []byte) (idx int) {
+	if len(s.needle) == 0 {
+		return
+	}
+	for offset := 0; offset < len(data); {
+		next := bytes.IndexAny(data[offset:], s.firsts)
+		if next < 0 {
+			break
+		}
+		idx = offset + next
+		if hasFoldPrefix(data[idx:], s.needle) {
+			return
+		}
+		_, width := utf8.DecodeRune(data[idx:])
+		offset = idx + width
+	}
+	idx = -1
+	return
+}
+
+// hasFoldPrefix returns true if the `data` starts with the `prefix`, under
+// Unicode simple case folding
+func hasFoldPrefix(data, prefix []byte) (ok bool) {
+	for len(prefix) > 0 {
+		if len(data) == 0 {
+			return
+		}
+		if a, b := data[0], prefix[0]; a < utf8.RuneSelf && b < utf8.RuneSelf {
+			// ASCII fast path
+			if a != b {
+				if 'A' <= a && a <= 'Z' {
+					a += 'a' - 'A'
+				}
+				if 'A' <= b && b <= 'Z' {
+					b += 'a' - 'A'
+				}
+				if a != b {
+					return
+				}
+			}
+			data, prefix = data[1:], prefix[1:]
+			continue
+		}
+		dr, dw := utf8.DecodeRune(data)
+		pr, pw := utf8.DecodeRune(prefix)
+		if dr == utf8.RuneError || pr == utf8.RuneError {
+			// invalid bytes only match themselves
+			if !bytes.Equal(data[:dw], prefix[:pw]) {
+				return
+			}
+		} else if !equalFoldRune(dr, pr) {
+			return
+		}
+		data, prefix = data[dw:], prefix[pw:]
+	}
+	ok = true
+	return
+}
+
+// equalFoldRune returns true if `a` and `b` are the same under Unicode simple
+// case folding
+func equalFoldRune(a, b rune) (equal bool) {
+	if equal = a == b; equal {
+		return
+	}
+	for f := unicode.SimpleFold(a); f != a; f = unicode.SimpleFold(f) {
+		if equal = f == b; equal {
+			return
+		}
+	}
+	return
+}
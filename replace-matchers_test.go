@@ -0,0 +1,123 @@
+// Copyright (c) 2024  The Go-Curses Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package replace
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestMatchers(t *testing.T) {
+	Convey("stringMatcher", t, func() {
+		So(stringMatcher("needle")([]byte("hay needle hay")), ShouldBeTrue)
+		So(stringMatcher("needle")([]byte("hay NEEDLE hay")), ShouldBeFalse)
+		So(stringMatcher("")([]byte("hay")), ShouldBeTrue)
+	})
+
+	Convey("stringInsensitiveMatcher", t, func() {
+		So(stringInsensitiveMatcher("needle")([]byte("hay NeEdLe hay")), ShouldBeTrue)
+		So(stringInsensitiveMatcher("NEEDLE")([]byte("hay needle")), ShouldBeTrue)
+		So(stringInsensitiveMatcher("needle")([]byte("hay needl")), ShouldBeFalse)
+		So(stringInsensitiveMatcher("needle")([]byte("nnneedle")), ShouldBeTrue)
+		So(stringInsensitiveMatcher("")([]byte("hay")), ShouldBeTrue)
+		So(stringInsensitiveMatcher("x")(nil), ShouldBeFalse)
+		So(stringInsensitiveMatcher("CAFÉ")([]byte("un café noir")), ShouldBeTrue)
+		So(stringInsensitiveMatcher("éclair")([]byte("ÉCLAIR")), ShouldBeTrue)
+		// the kelvin sign folds to k
+		So(stringInsensitiveMatcher("kelvin")([]byte("\u212aELVIN")), ShouldBeTrue)
+		So(stringInsensitiveMatcher("\u212aelvin")([]byte("KELVIN")), ShouldBeTrue)
+		So(stringInsensitiveMatcher("caf\xe9")([]byte("CAF\xe9")), ShouldBeTrue)
+		So(stringInsensitiveMatcher("caf\xe9")([]byte("CAF\xe8")), ShouldBeFalse)
+	})
+
+	Convey("regexpLinesMatcher", t, func() {
+		So(regexpLinesMatcher(regexp.MustCompile(`^two$`))([]byte("one\ntwo\nthree")), ShouldBeFalse)
+		So(regexpLinesMatcher(regexp.MustCompile(`(?m)^two$`))([]byte("one\ntwo\nthree")), ShouldBeTrue)
+		So(regexpLinesMatcher(regexp.MustCompile(`^two\n$`))([]byte("one\ntwo\nthree")), ShouldBeTrue)
+		So(regexpLinesMatcher(regexp.MustCompile(`^three$`))([]byte("one\ntwo\nthree")), ShouldBeTrue)
+		So(regexpLinesMatcher(regexp.MustCompile(`one.two`))([]byte("one\ntwo")), ShouldBeFalse)
+		So(regexpLinesMatcher(regexp.MustCompile(`^$`))([]byte("one\n")), ShouldBeTrue)
+		So(regexpLinesMatcher(regexp.MustCompile(`^$`))(nil), ShouldBeTrue)
+	})
+
+	Convey("matchers do not allocate", t, func() {
+		data := tMakeMatcherInput(64 << 10)
+		// regexp allocates under the race detector, see the benchmarks for
+		// the regexpLinesMatcher allocations
+		for _, matcher := range []FindAllMatcherFn{
+			stringMatcher("not present"),
+			stringInsensitiveMatcher("NOT PRESENT"),
+		} {
+			So(testing.AllocsPerRun(10, func() { matcher(data) }), ShouldEqual, 0)
+		}
+	})
+}
+
+func tMakeMatcherInput(size int) (data []byte) {
+	var buffer strings.Builder
+	for buffer.Len() < size {
+		buffer.WriteString("The quick brown fox jumps over the lazy dog, naïvely.\n")
+	}
+	data = []byte(buffer.String()[:size])
+	return
+}
+
+// benchmarkMatcher compares the `matcher` with the string conversion based
+// `previous` form it replaces
+func benchmarkMatcher(b *testing.B, matcher, previous FindAllMatcherFn) {
+	data := tMakeMatcherInput(2 << 20)
+	for name, fn := range map[string]FindAllMatcherFn{"bytes": matcher, "strings": previous} {
+		b.Run(name, func(b *testing.B) {
+			b.SetBytes(int64(len(data)))
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				_ = fn(data)
+			}
+		})
+	}
+}
+
+func BenchmarkMatcherString(b *testing.B) {
+	benchmarkMatcher(b, stringMatcher("not present"), func(data []byte) (matched bool) {
+		return strings.Contains(string(data), "not present")
+	})
+}
+
+func BenchmarkMatcherStringInsensitive(b *testing.B) {
+	benchmarkMatcher(b, stringInsensitiveMatcher("NOT PRESENT"), func(data []byte) (matched bool) {
+		return strings.Contains(strings.ToLower(string(data)), strings.ToLower("NOT PRESENT"))
+	})
+}
+
+func BenchmarkMatcherRegexpLines(b *testing.B) {
+	search := regexp.MustCompile(`not present`)
+	benchmarkMatcher(b, regexpLinesMatcher(search), func(data []byte) (matched bool) {
+		lines := strings.Split(string(data), "\n")
+		last := len(lines) - 1
+		for idx, line := range lines {
+			if idx < last {
+				line += "\n"
+			}
+			if matched = search.MatchString(line); matched {
+				return
+			}
+		}
+		return
+	})
+}
@@ -0,0 +1,49 @@
+// Copyright (c) 2024  The Go-Curses Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux
+
+package replace
+
+import (
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// mmapFile maps the entire `name` file into memory for reading, `release`
+// must be called once the `data` is no longer needed
+func mmapFile(name string) (data []byte, release func(), err error) {
+	var fh *os.File
+	if fh, err = os.Open(name); err != nil {
+		return
+	}
+	defer fh.Close()
+	var info os.FileInfo
+	if info, err = fh.Stat(); err != nil {
+		return
+	} else if size := info.Size(); size <= 0 || int64(int(size)) != size {
+		// empty files can't be mapped and huge ones don't fit
+		err = errMmapUnsupported
+		return
+	}
+	if data, err = unix.Mmap(int(fh.Fd()), 0, int(info.Size()), unix.PROT_READ, unix.MAP_SHARED); err != nil {
+		return
+	}
+	_ = unix.Madvise(data, unix.MADV_SEQUENTIAL)
+	release = func() {
+		_ = unix.Munmap(data)
+	}
+	return
+}
@@ -0,0 +1,83 @@
+// Copyright (c) 2024  The Go-Curses Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package replace
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestMmap(t *testing.T) {
+	Convey("mmapFile", t, func() {
+		dir := tMakeTree(t, "data.txt")
+		empty := filepath.Join(dir, "empty.txt")
+		So(os.WriteFile(empty, nil, 0644), ShouldBeNil)
+
+		data, release, err := mmapFile(filepath.Join(dir, "data.txt"))
+		if runtime.GOOS != "linux" {
+			So(errors.Is(err, errMmapUnsupported), ShouldBeTrue)
+			return
+		}
+		So(err, ShouldBeNil)
+		So(string(data), ShouldEqual, "data.txt")
+		release()
+
+		_, _, err = mmapFile(empty)
+		So(errors.Is(err, errMmapUnsupported), ShouldBeTrue)
+		_, _, err = mmapFile(filepath.Join(dir, "missing.txt"))
+		So(errors.Is(err, os.ErrNotExist), ShouldBeTrue)
+
+		data, release, err = cOsWalker{}.mapFile(empty)
+		So(err, ShouldBeNil)
+		So(data, ShouldBeEmpty)
+		release()
+	})
+
+	Convey("finders with MmapFileSize", t, func() {
+		// memory mapping is opt-in
+		So(MmapFileSize, ShouldEqual, 0)
+		orig := MmapFileSize
+		defer func() { MmapFileSize = orig }()
+		dir := tMakeTree(t, "one.txt", "two.txt", "bin/tool")
+		So(os.WriteFile(filepath.Join(dir, "bin/tool"), []byte("\x7fELF\x00one"), 0755), ShouldBeNil)
+		So(os.WriteFile(filepath.Join(dir, "empty.txt"), nil, 0644), ShouldBeNil)
+
+		for _, size := range []int64{0, 1} {
+			MmapFileSize = size
+			var binaries int
+			files, matches, err := FindAllMatcherWith([]string{dir}, FindOptions{
+				Recurse: true,
+				Binary:  BinarySearch,
+				Locator: LocateString("one"),
+			}, func(file string, matched bool, err error) {
+				if errors.Is(err, ErrBinaryMatch) {
+					binaries += 1
+				}
+			}, func(data []byte) (matched bool) {
+				return strings.Contains(string(data), "one")
+			})
+			So(err, ShouldBeNil)
+			So(files, ShouldHaveLength, 4)
+			So(matches, ShouldResemble, []string{filepath.Join(dir, "one.txt")})
+			So(binaries, ShouldEqual, 1)
+		}
+	})
+}
@@ -0,0 +1,129 @@
+// Copyright (c) 2024  The Go-Curses Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package replace
+
+import (
+	"io/fs"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/maruel/natural"
+)
+
+// FindOrder specifies the order in which the finders report files. Any order
+// other than the default requires the entire walk to complete before the
+// first file is reported, trading the streaming of results for output which
+// is stable across machines and filesystems. Archive members are always
+// reported in the order stored within their archive
+type FindOrder uint8
+
+const (
+	// OrderDefault reports the files of each directory before walking its
+	// subdirectories, in the order listed by the filesystem walker
+	OrderDefault FindOrder = iota
+	// OrderLexical sorts files by their full path, byte by byte
+	OrderLexical
+	// OrderNatural sorts files by their full path, comparing runs of digits
+	// numerically so that "file2" comes before "file10"
+	OrderNatural
+	// OrderDepthFirst sorts files and directories together by name, with
+	// each directory's contents immediately following its position
+	OrderDepthFirst
+	// OrderBreadthFirst sorts all files of a given depth before those of the
+	// next depth, and by name within each depth
+	OrderBreadthFirst
+	// OrderSize sorts files by size, smallest first and by path when equal
+	OrderSize
+	// OrderModTime sorts files by modification time, oldest first and by
+	// path when equal
+	OrderModTime
+)
+
+// cFoundFile is a file found by the cIncluder, held for sorting
+type cFoundFile struct {
+	path  string
+	info  fs.FileInfo
+	depth int
+}
+
+// sortFoundFiles sorts the `files` in-place according to the `order` given
+func sortFoundFiles(files []cFoundFile, order FindOrder) {
+	var less func(a, b cFoundFile) (less bool)
+	switch order {
+	case OrderLexical:
+		less = func(a, b cFoundFile) (less bool) {
+			less = a.path < b.path
+			return
+		}
+	case OrderNatural:
+		less = func(a, b cFoundFile) (less bool) {
+			less = natural.Less(a.path, b.path)
+			return
+		}
+	case OrderDepthFirst:
+		less = func(a, b cFoundFile) (less bool) {
+			less = comparePathNames(a.path, b.path) < 0
+			return
+		}
+	case OrderBreadthFirst:
+		less = func(a, b cFoundFile) (less bool) {
+			if a.depth != b.depth {
+				less = a.depth < b.depth
+				return
+			}
+			less = comparePathNames(a.path, b.path) < 0
+			return
+		}
+	case OrderSize:
+		less = func(a, b cFoundFile) (less bool) {
+			if as, bs := a.info.Size(), b.info.Size(); as != bs {
+				less = as < bs
+				return
+			}
+			less = a.path < b.path
+			return
+		}
+	case OrderModTime:
+		less = func(a, b cFoundFile) (less bool) {
+			if at, bt := a.info.ModTime(), b.info.ModTime(); !at.Equal(bt) {
+				less = at.Before(bt)
+				return
+			}
+			less = a.path < b.path
+			return
+		}
+	default:
+		return
+	}
+	sort.SliceStable(files, func(i, j int) (ok bool) {
+		ok = less(files[i], files[j])
+		return
+	})
+}
+
+// comparePathNames compares the `a` and `b` paths one name at a time, so that
+// the contents of a directory sort immediately after the directory itself
+func comparePathNames(a, b string) (cmp int) {
+	an := strings.Split(filepath.ToSlash(a), "/")
+	bn := strings.Split(filepath.ToSlash(b), "/")
+	for idx := 0; idx < len(an) && idx < len(bn); idx++ {
+		if cmp = strings.Compare(an[idx], bn[idx]); cmp != 0 {
+			return
+		}
+	}
+	cmp = len(an) - len(bn)
+	return
+}
@@ -0,0 +1,110 @@
+// Copyright (c) 2024  The Go-Curses Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package replace
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestFindOrder(t *testing.T) {
+	Convey("FindOrder", t, func() {
+		dir := tMakeTree(t, "b.txt", "a10.txt", "a2.txt", "a/z.txt", "a-b/c.txt", "a/deep/x.txt")
+		find := func(order FindOrder) (found []string) {
+			files, err := FindAllIncludedWith([]string{dir}, FindOptions{Recurse: true, Order: order})
+			So(err, ShouldBeNil)
+			for _, file := range files {
+				rel, _ := filepath.Rel(dir, file)
+				found = append(found, filepath.ToSlash(rel))
+			}
+			return
+		}
+
+		Convey("lexical", func() {
+			So(find(OrderLexical), ShouldResemble, []string{
+				"a-b/c.txt", "a/deep/x.txt", "a/z.txt", "a10.txt", "a2.txt", "b.txt",
+			})
+		})
+
+		Convey("natural", func() {
+			So(find(OrderNatural), ShouldResemble, []string{
+				"a-b/c.txt", "a/deep/x.txt", "a/z.txt", "a2.txt", "a10.txt", "b.txt",
+			})
+		})
+
+		Convey("depth-first", func() {
+			So(find(OrderDepthFirst), ShouldResemble, []string{
+				"a/deep/x.txt", "a/z.txt", "a-b/c.txt", "a10.txt", "a2.txt", "b.txt",
+			})
+		})
+
+		Convey("breadth-first", func() {
+			So(find(OrderBreadthFirst), ShouldResemble, []string{
+				"a10.txt", "a2.txt", "b.txt", "a/z.txt", "a-b/c.txt", "a/deep/x.txt",
+			})
+		})
+
+		Convey("size", func() {
+			// the file contents are the file names
+			So(find(OrderSize), ShouldResemble, []string{
+				"b.txt", "a2.txt", "a/z.txt", "a10.txt", "a-b/c.txt", "a/deep/x.txt",
+			})
+		})
+
+		Convey("modification time", func() {
+			then := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+			for idx, name := range []string{"a2.txt", "a/deep/x.txt", "b.txt", "a-b/c.txt", "a10.txt", "a/z.txt"} {
+				stamp := then.Add(time.Duration(idx) * time.Hour)
+				So(os.Chtimes(filepath.Join(dir, name), stamp, stamp), ShouldBeNil)
+			}
+			So(find(OrderModTime), ShouldResemble, []string{
+				"a2.txt", "a/deep/x.txt", "b.txt", "a-b/c.txt", "a10.txt", "a/z.txt",
+			})
+		})
+
+		Convey("finders and streams", func() {
+			matcher := func(data []byte) (matched bool) {
+				return strings.HasPrefix(string(data), "a")
+			}
+			files, matches, err := FindAllMatcherWith([]string{dir}, FindOptions{Recurse: true, Order: OrderBreadthFirst}, nil, matcher)
+			So(err, ShouldBeNil)
+			So(files, ShouldHaveLength, 6)
+			So(files[0], ShouldEqual, filepath.Join(dir, "a10.txt"))
+			So(matches, ShouldResemble, []string{
+				filepath.Join(dir, "a10.txt"),
+				filepath.Join(dir, "a2.txt"),
+				filepath.Join(dir, "a/z.txt"),
+				filepath.Join(dir, "a-b/c.txt"),
+				filepath.Join(dir, "a/deep/x.txt"),
+			})
+
+			var streamed []string
+			err = FindAllStream([]string{dir}, FindOptions{Recurse: true, Order: OrderLexical}, matcher, func(result FindResult) (proceed bool) {
+				streamed = append(streamed, result.File)
+				return len(streamed) < 2
+			})
+			So(err, ShouldBeNil)
+			So(streamed, ShouldResemble, []string{
+				filepath.Join(dir, "a-b/c.txt"),
+				filepath.Join(dir, "a/deep/x.txt"),
+			})
+		})
+	})
+}
@@ -0,0 +1,206 @@
+// Copyright (c) 2024  The Go-Curses Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package replace
+
+import (
+	"io/fs"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/gabriel-vasile/mimetype"
+)
+
+// FileCandidate describes a file being considered by a FilePredicate
+type FileCandidate struct {
+	// Path is the file path as found
+	Path string
+	// Info is the file information, with any symlinks followed
+	Info fs.FileInfo
+
+	fsys fs.FS
+}
+
+// Open opens the file for reading, from the fs.FS being searched or from the
+// local filesystem
+func (c FileCandidate) Open() (fh fs.File, err error) {
+	if c.fsys != nil {
+		fh, err = c.fsys.Open(c.Path)
+		return
+	}
+	fh, err = os.Open(c.Path)
+	return
+}
+
+// FilePredicate is the function signature for filtering the files found by
+// the finders, see FindOptions.Filter
+type FilePredicate func(candidate FileCandidate) (ok bool)
+
+// FileAll returns a FilePredicate which is true when all of the `predicates`
+// are true, or when there are no `predicates`
+func FileAll(predicates ...FilePredicate) (predicate FilePredicate) {
+	predicate = func(candidate FileCandidate) (ok bool) {
+		for _, p := range predicates {
+			if !p(candidate) {
+				return
+			}
+		}
+		ok = true
+		return
+	}
+	return
+}
+
+// FileAny returns a FilePredicate which is true when any of the `predicates`
+// are true
+func FileAny(predicates ...FilePredicate) (predicate FilePredicate) {
+	predicate = func(candidate FileCandidate) (ok bool) {
+		for _, p := range predicates {
+			if ok = p(candidate); ok {
+				return
+			}
+		}
+		return
+	}
+	return
+}
+
+// FileNot returns a FilePredicate which inverts the given `predicate`
+func FileNot(predicate FilePredicate) (inverted FilePredicate) {
+	inverted = func(candidate FileCandidate) (ok bool) {
+		ok = !predicate(candidate)
+		return
+	}
+	return
+}
+
+// FileMinSize returns a FilePredicate which is true for files of at least
+// `size` bytes
+func FileMinSize(size int64) (predicate FilePredicate) {
+	predicate = func(candidate FileCandidate) (ok bool) {
+		ok = candidate.Info.Size() >= size
+		return
+	}
+	return
+}
+
+// FileMaxSize returns a FilePredicate which is true for files of at most
+// `size` bytes
+func FileMaxSize(size int64) (predicate FilePredicate) {
+	predicate = func(candidate FileCandidate) (ok bool) {
+		ok = candidate.Info.Size() <= size
+		return
+	}
+	return
+}
+
+// FileModifiedAfter returns a FilePredicate which is true for files modified
+// after the time given
+func FileModifiedAfter(t time.Time) (predicate FilePredicate) {
+	predicate = func(candidate FileCandidate) (ok bool) {
+		ok = candidate.Info.ModTime().After(t)
+		return
+	}
+	return
+}
+
+// FileModifiedBefore returns a FilePredicate which is true for files modified
+// before the time given
+func FileModifiedBefore(t time.Time) (predicate FilePredicate) {
+	predicate = func(candidate FileCandidate) (ok bool) {
+		ok = candidate.Info.ModTime().Before(t)
+		return
+	}
+	return
+}
+
+// FileRegular is a FilePredicate which is true for regular files, excluding
+// devices, named pipes, sockets and the like
+func FileRegular(candidate FileCandidate) (ok bool) {
+	ok = candidate.Info.Mode().IsRegular()
+	return
+}
+
+// FileExecutable is a FilePredicate which is true for files with any of the
+// executable permission bits set
+func FileExecutable(candidate FileCandidate) (ok bool) {
+	ok = candidate.Info.Mode().Perm()&0111 != 0
+	return
+}
+
+// FileMode returns a FilePredicate which is true for files with all of the
+// `mode` bits given, for example 0600 for files readable and writable by
+// their owner
+func FileMode(mode fs.FileMode) (predicate FilePredicate) {
+	predicate = func(candidate FileCandidate) (ok bool) {
+		ok = candidate.Info.Mode()&mode == mode
+		return
+	}
+	return
+}
+
+// FileOwner returns a FilePredicate which is true for files owned by the user
+// ID given, on platforms without file ownership this is always false
+func FileOwner(uid int) (predicate FilePredicate) {
+	predicate = func(candidate FileCandidate) (ok bool) {
+		owner, valid := fileOwner(candidate.Info)
+		ok = valid && owner == uid
+		return
+	}
+	return
+}
+
+// FileMimeType returns a FilePredicate which is true for files with content
+// detected as one of the MIME `types` given, or a sub-type of them. For
+// example "text/plain" also matches Python scripts and "text/*" matches all
+// text types
+func FileMimeType(types ...string) (predicate FilePredicate) {
+	predicate = func(candidate FileCandidate) (ok bool) {
+		fh, err := candidate.Open()
+		if err != nil {
+			return
+		}
+		defer fh.Close()
+		var kind *mimetype.MIME
+		if kind, err = mimetype.DetectReader(fh); err != nil {
+			return
+		}
+		ok = isMimeType(kind, types)
+		return
+	}
+	return
+}
+
+// isMimeType returns true if the `kind` given is one of the MIME `types`, or
+// a sub-type of them, see FileMimeType
+func isMimeType(kind *mimetype.MIME, types []string) (ok bool) {
+	for detected := kind; kind != nil; kind = kind.Parent() {
+		if kind != detected && kind.Parent() == nil {
+			// everything is a sub-type of application/octet-stream
+			break
+		}
+		name, _, _ := strings.Cut(kind.String(), ";")
+		for _, t := range types {
+			if prefix, wild := strings.CutSuffix(t, "/*"); wild {
+				if ok = strings.HasPrefix(name, prefix+"/"); ok {
+					return
+				}
+			} else if ok = kind.Is(t); ok {
+				return
+			}
+		}
+	}
+	return
+}
@@ -0,0 +1,93 @@
+// Copyright (c) 2024  The Go-Curses Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package replace
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestPredicates(t *testing.T) {
+
+	Convey("File predicates", t, func() {
+		dir := tMakeTree(t, "small.txt", "script.py", "data.bin", "old.txt")
+		So(os.WriteFile(filepath.Join(dir, "script.py"), []byte("#!/usr/bin/env python\nprint(1)\n"), 0644), ShouldBeNil)
+		So(os.Chmod(filepath.Join(dir, "script.py"), 0755), ShouldBeNil)
+		So(os.WriteFile(filepath.Join(dir, "data.bin"), []byte("\x00\x01\x02\x03 binary data of some length"), 0644), ShouldBeNil)
+		lastMonth := time.Now().Add(-30 * 24 * time.Hour)
+		So(os.Chtimes(filepath.Join(dir, "old.txt"), lastMonth, lastMonth), ShouldBeNil)
+
+		find := func(filter FilePredicate) (names []string) {
+			found, err := FindAllIncludedWith([]string{dir}, FindOptions{Recurse: true, Filter: filter})
+			So(err, ShouldBeNil)
+			for _, file := range found {
+				names = append(names, filepath.Base(file))
+			}
+			return
+		}
+		lastWeek := time.Now().Add(-7 * 24 * time.Hour)
+
+		So(find(nil), ShouldResemble, []string{"data.bin", "old.txt", "script.py", "small.txt"})
+		So(find(FileMinSize(10)), ShouldResemble, []string{"data.bin", "script.py"})
+		So(find(FileMaxSize(10)), ShouldResemble, []string{"old.txt", "small.txt"})
+		So(find(FileModifiedAfter(lastWeek)), ShouldResemble, []string{"data.bin", "script.py", "small.txt"})
+		So(find(FileModifiedBefore(lastWeek)), ShouldResemble, []string{"old.txt"})
+		So(find(FileRegular), ShouldHaveLength, 4)
+		So(find(FileExecutable), ShouldResemble, []string{"script.py"})
+		So(find(FileMode(0750)), ShouldResemble, []string{"script.py"})
+		So(find(FileOwner(os.Getuid())), ShouldHaveLength, 4)
+		So(find(FileOwner(os.Getuid()+1)), ShouldBeEmpty)
+		So(find(FileMimeType("text/x-python")), ShouldResemble, []string{"script.py"})
+		So(find(FileMimeType("text/plain")), ShouldResemble, []string{"old.txt", "script.py", "small.txt"})
+		So(find(FileMimeType("application/*")), ShouldResemble, []string{"data.bin"})
+
+		So(find(FileAll(FileExecutable, FileModifiedAfter(lastWeek))), ShouldResemble, []string{"script.py"})
+		So(find(FileAll()), ShouldHaveLength, 4)
+		So(find(FileAny(FileExecutable, FileModifiedBefore(lastWeek))), ShouldResemble, []string{"old.txt", "script.py"})
+		So(find(FileAny()), ShouldBeEmpty)
+		So(find(FileNot(FileMimeType("text/*"))), ShouldResemble, []string{"data.bin"})
+	})
+
+	Convey("File predicates with an fs.FS", t, func() {
+		fsys := fstest.MapFS{
+			"run.py":   {Data: []byte("#!/usr/bin/python\nexit(0)\n"), Mode: 0755},
+			"note.txt": {Data: []byte("just a note"), Mode: 0644},
+		}
+		found, err := FindAllIncludedWith([]string{"."}, FindOptions{
+			FS:      fsys,
+			Recurse: true,
+			Filter:  FileAll(FileExecutable, FileMimeType("text/x-python")),
+		})
+		So(err, ShouldBeNil)
+		So(found, ShouldResemble, []string{"run.py"})
+	})
+
+	Convey("FindAllMatcherWith uses the filter", t, func() {
+		dir := tMakeTree(t, "keep.txt", "skip.txt")
+		So(os.Chmod(filepath.Join(dir, "keep.txt"), 0755), ShouldBeNil)
+		files, matches, err := FindAllMatcherWith([]string{dir}, FindOptions{Recurse: true, Filter: FileExecutable}, nil, func(data []byte) (matched bool) {
+			return true
+		})
+		So(err, ShouldBeNil)
+		So(files, ShouldResemble, []string{filepath.Join(dir, "keep.txt")})
+		So(matches, ShouldResemble, files)
+	})
+
+}
@@ -0,0 +1,39 @@
+// Copyright (c) 2024  The Go-Curses Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package replace
+
+import (
+	"regexp"
+
+	"github.com/go-corelibs/globs"
+)
+
+// RegexRenames uses Regex to FindAllRenames
+func RegexRenames(search *regexp.Regexp, replace string, targets []string, includeHidden, recurse bool, include, exclude globs.Globs) (renames Renames, err error) {
+	renames, err = FindAllRenames(targets, includeHidden, recurse, include, exclude, func(name string) (modified string, count int) {
+		modified, count = Regex(search, replace, name)
+		return
+	})
+	return
+}
+
+// RegexPreserveRenames uses RegexPreserve to FindAllRenames
+func RegexPreserveRenames(search *regexp.Regexp, replace string, targets []string, includeHidden, recurse bool, include, exclude globs.Globs) (renames Renames, err error) {
+	renames, err = FindAllRenames(targets, includeHidden, recurse, include, exclude, func(name string) (modified string, count int) {
+		modified, count = RegexPreserve(search, replace, name)
+		return
+	})
+	return
+}
@@ -0,0 +1,40 @@
+// Copyright (c) 2024  The Go-Curses Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package replace
+
+import (
+	"path/filepath"
+	"regexp"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestRegexRenames(t *testing.T) {
+	dir := tMakeTree(t, "UserService.go", "user_service.go")
+
+	Convey("RegexRenames", t, func() {
+		renames, err := RegexRenames(regexp.MustCompile(`^user_(\w+)\.go$`), "${1}_account.go", []string{dir}, false, true, nil, nil)
+		So(err, ShouldBeNil)
+		So(renames, ShouldResemble, Renames{{filepath.Join(dir, "user_service.go"), filepath.Join(dir, "service_account.go")}})
+	})
+
+	Convey("RegexPreserveRenames", t, func() {
+		renames, err := RegexPreserveRenames(regexp.MustCompile(`(?i)user`), "account", []string{dir}, false, true, nil, nil)
+		So(err, ShouldBeNil)
+		So(len(renames), ShouldEqual, 2)
+		So(renames[0].Target, ShouldEqual, filepath.Join(dir, "AccountService.go"))
+	})
+}
@@ -0,0 +1,46 @@
+// Copyright (c) 2024  The Go-Curses Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package replace
+
+import (
+	"github.com/go-corelibs/globs"
+)
+
+// StringRenames uses String to FindAllRenames
+func StringRenames(search, replace string, targets []string, includeHidden, recurse bool, include, exclude globs.Globs) (renames Renames, err error) {
+	renames, err = FindAllRenames(targets, includeHidden, recurse, include, exclude, func(name string) (modified string, count int) {
+		modified, count = String(search, replace, name)
+		return
+	})
+	return
+}
+
+// StringInsensitiveRenames uses StringInsensitive to FindAllRenames
+func StringInsensitiveRenames(search, replace string, targets []string, includeHidden, recurse bool, include, exclude globs.Globs) (renames Renames, err error) {
+	renames, err = FindAllRenames(targets, includeHidden, recurse, include, exclude, func(name string) (modified string, count int) {
+		modified, count = StringInsensitive(search, replace, name)
+		return
+	})
+	return
+}
+
+// StringPreserveRenames uses StringPreserve to FindAllRenames
+func StringPreserveRenames(search, replace string, targets []string, includeHidden, recurse bool, include, exclude globs.Globs) (renames Renames, err error) {
+	renames, err = FindAllRenames(targets, includeHidden, recurse, include, exclude, func(name string) (modified string, count int) {
+		modified, count = StringPreserve(search, replace, name)
+		return
+	})
+	return
+}
@@ -0,0 +1,230 @@
+// Copyright (c) 2024  The Go-Curses Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package replace
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/go-corelibs/globs"
+	"github.com/go-corelibs/path"
+)
+
+var (
+	ErrRenameCollision = errors.New("rename collision")
+	ErrRenameCycle     = errors.New("rename cycle")
+	ErrRenameInvalid   = errors.New("invalid rename")
+)
+
+// RenameFn is the function signature for deriving a new base name from an
+// existing one, the same as the String and Regex functions with their search
+// and replace arguments already applied
+type RenameFn func(name string) (modified string, count int)
+
+// Rename describes moving one file or directory from Source to Target, both
+// within the same parent directory
+type Rename struct {
+	Source string
+	Target string
+}
+
+// String returns the Rename in "source -> target" form
+func (r Rename) String() (text string) {
+	text = r.Source + " -> " + r.Target
+	return
+}
+
+// RenameError is the error type returned when a Rename can't be planned or
+// applied, use errors.Is with ErrRenameCollision, ErrRenameCycle or
+// ErrRenameInvalid to check the reason
+type RenameError struct {
+	Rename
+	Err error
+}
+
+func (e *RenameError) Error() (message string) {
+	message = fmt.Sprintf("%s: %v", e.Rename, e.Err)
+	return
+}
+
+func (e *RenameError) Unwrap() (err error) {
+	err = e.Err
+	return
+}
+
+// Renames is a list of Rename operations, ordered such that applying them
+// in sequence is always safe: deeper paths are renamed before their parent
+// directories and chains of renames within a directory (a -> b, b -> c) are
+// ordered so that nothing is overwritten
+type Renames []Rename
+
+// String returns the list of renames, one per line, suitable for dry-run
+// output
+func (r Renames) String() (text string) {
+	var buffer strings.Builder
+	for _, rename := range r {
+		buffer.WriteString(rename.String())
+		buffer.WriteString("\n")
+	}
+	text = buffer.String()
+	return
+}
+
+// Apply performs each rename in order, stopping at the first error. Targets
+// are checked again just before renaming so that files created since the
+// Renames were found are never overwritten
+func (r Renames) Apply() (err error) {
+	for _, rename := range r {
+		if _, ee := os.Lstat(rename.Target); ee == nil && !isSameFile(rename.Source, rename.Target) {
+			err = &RenameError{Rename: rename, Err: ErrRenameCollision}
+			return
+		} else if ee = os.Rename(rename.Source, rename.Target); ee != nil {
+			err = &RenameError{Rename: rename, Err: ee}
+			return
+		}
+	}
+	return
+}
+
+// FindAllRenames uses FindAllIncluded to find all files and, when recursing,
+// all directories within the targets given (subject only to the exclude
+// globs), and uses `fn` to derive the new base names. Renames which would
+// collide with existing paths or each other, or which form cycles, are
+// reported with a RenameError
+func FindAllRenames(targets []string, includeHidden, recurse bool, include, exclude globs.Globs, fn RenameFn) (renames Renames, err error) {
+	var candidates []string
+	unique := make(map[string]struct{})
+	add := func(p string) {
+		if _, present := unique[p]; !present {
+			unique[p] = struct{}{}
+			candidates = append(candidates, p)
+		}
+	}
+
+	for _, file := range FindAllIncluded(targets, includeHidden, false, false, recurse, include, exclude) {
+		add(file)
+	}
+	if recurse {
+		for _, target := range targets {
+			if path.IsDir(target) {
+				dirs, _ := path.ListAllDirs(target, includeHidden)
+				for _, dir := range dirs {
+					if IsIncluded(nil, exclude, dir) {
+						add(dir)
+					}
+				}
+			}
+		}
+	}
+
+	sources := make(map[string]Rename)
+	targeted := make(map[string]Rename)
+	for _, source := range candidates {
+		base := filepath.Base(source)
+		modified, count := fn(base)
+		if count == 0 || modified == base {
+			continue
+		}
+		rename := Rename{Source: source, Target: filepath.Join(filepath.Dir(source), modified)}
+		if modified == "" || modified == "." || modified == ".." || strings.ContainsRune(modified, filepath.Separator) || strings.ContainsRune(modified, '/') {
+			err = &RenameError{Rename: rename, Err: ErrRenameInvalid}
+			return
+		} else if other, present := targeted[rename.Target]; present {
+			err = &RenameError{Rename: rename, Err: fmt.Errorf("%w with %s", ErrRenameCollision, other.Source)}
+			return
+		}
+		sources[rename.Source] = rename
+		targeted[rename.Target] = rename
+	}
+
+	for _, rename := range sources {
+		if _, moving := sources[rename.Target]; moving {
+			// target is being renamed away first
+			continue
+		} else if _, ee := os.Lstat(rename.Target); ee == nil && !isSameFile(rename.Source, rename.Target) {
+			err = &RenameError{Rename: rename, Err: ErrRenameCollision}
+			return
+		}
+	}
+
+	renames, err = orderRenames(sources)
+	return
+}
+
+// orderRenames sorts the renames deepest first and within each directory
+// makes sure that any rename targeting another's source happens after it
+func orderRenames(sources map[string]Rename) (renames Renames, err error) {
+	keys := make([]string, 0, len(sources))
+	for key := range sources {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) (less bool) {
+		a, b := strings.Count(keys[i], string(filepath.Separator)), strings.Count(keys[j], string(filepath.Separator))
+		if a != b {
+			less = a > b
+			return
+		}
+		less = keys[i] < keys[j]
+		return
+	})
+
+	const (
+		pending = iota
+		visiting
+		visited
+	)
+	state := make(map[string]int)
+	var visit func(rename Rename, chain []string) (err error)
+	visit = func(rename Rename, chain []string) (err error) {
+		switch state[rename.Source] {
+		case visited:
+			return
+		case visiting:
+			err = &RenameError{Rename: rename, Err: fmt.Errorf("%w: %s", ErrRenameCycle, strings.Join(append(chain, rename.Source), " -> "))}
+			return
+		}
+		state[rename.Source] = visiting
+		if next, present := sources[rename.Target]; present {
+			// next must move out of the way first
+			if err = visit(next, append(chain, rename.Source)); err != nil {
+				return
+			}
+		}
+		state[rename.Source] = visited
+		renames = append(renames, rename)
+		return
+	}
+
+	for _, key := range keys {
+		if err = visit(sources[key], nil); err != nil {
+			renames = nil
+			return
+		}
+	}
+	return
+}
+
+func isSameFile(a, b string) (same bool) {
+	if ai, err := os.Lstat(a); err == nil {
+		if bi, err := os.Lstat(b); err == nil {
+			same = os.SameFile(ai, bi)
+		}
+	}
+	return
+}
@@ -0,0 +1,146 @@
+// Copyright (c) 2024  The Go-Curses Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package replace
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/go-corelibs/globs"
+)
+
+func tMakeTree(t *testing.T, files ...string) (dir string) {
+	dir = t.TempDir()
+	for _, file := range files {
+		target := filepath.Join(dir, filepath.FromSlash(file))
+		_ = os.MkdirAll(filepath.Dir(target), 0755)
+		_ = os.WriteFile(target, []byte(file), 0644)
+	}
+	return
+}
+
+func tRename(search, replace string) RenameFn {
+	return func(name string) (modified string, count int) {
+		modified, count = String(search, replace, name)
+		return
+	}
+}
+
+func TestRename(t *testing.T) {
+
+	Convey("FindAllRenames", t, func() {
+		dir := tMakeTree(t,
+			"user/user_service.go",
+			"user/user_test.go",
+			"user/models/user.go",
+			"user/.user_hidden",
+			"docs/readme.md",
+		)
+		renames, err := FindAllRenames([]string{dir}, false, true, nil, nil, tRename("user", "account"))
+		So(err, ShouldBeNil)
+		So(renames.String(), ShouldEqual, ""+
+			filepath.Join(dir, "user/models/user.go")+" -> "+filepath.Join(dir, "user/models/account.go")+"\n"+
+			filepath.Join(dir, "user/user_service.go")+" -> "+filepath.Join(dir, "user/account_service.go")+"\n"+
+			filepath.Join(dir, "user/user_test.go")+" -> "+filepath.Join(dir, "user/account_test.go")+"\n"+
+			filepath.Join(dir, "user")+" -> "+filepath.Join(dir, "account")+"\n",
+		)
+		So(renames.Apply(), ShouldBeNil)
+		So(FindAllIncluded([]string{dir}, true, false, false, true, nil, nil), ShouldResemble, []string{
+			filepath.Join(dir, "account/.user_hidden"),
+			filepath.Join(dir, "account/account_service.go"),
+			filepath.Join(dir, "account/account_test.go"),
+			filepath.Join(dir, "account/models/account.go"),
+			filepath.Join(dir, "docs/readme.md"),
+		})
+	})
+
+	Convey("include and exclude", t, func() {
+		dir := tMakeTree(t, "user/user.go", "user/user.md", "vendor/user.go")
+		include, _ := globs.Parse("*.go")
+		exclude, _ := globs.Parse("*/vendor/*")
+		renames, err := FindAllRenames([]string{dir}, false, true, include, exclude, tRename("user", "account"))
+		So(err, ShouldBeNil)
+		So(renames, ShouldResemble, Renames{
+			{filepath.Join(dir, "user/user.go"), filepath.Join(dir, "user/account.go")},
+			{filepath.Join(dir, "user"), filepath.Join(dir, "account")},
+		})
+	})
+
+	Convey("chains are ordered", t, func() {
+		dir := tMakeTree(t, "a1.txt", "a2.txt")
+		renames, err := FindAllRenames([]string{dir}, false, true, nil, nil, func(name string) (modified string, count int) {
+			switch name {
+			case "a1.txt":
+				modified, count = "a2.txt", 1
+			case "a2.txt":
+				modified, count = "a3.txt", 1
+			}
+			return
+		})
+		So(err, ShouldBeNil)
+		So(renames, ShouldResemble, Renames{
+			{filepath.Join(dir, "a2.txt"), filepath.Join(dir, "a3.txt")},
+			{filepath.Join(dir, "a1.txt"), filepath.Join(dir, "a2.txt")},
+		})
+		So(renames.Apply(), ShouldBeNil)
+		data, _ := os.ReadFile(filepath.Join(dir, "a3.txt"))
+		So(string(data), ShouldEqual, "a2.txt")
+	})
+
+	Convey("cycles are detected", t, func() {
+		dir := tMakeTree(t, "a.txt", "b.txt")
+		_, err := FindAllRenames([]string{dir}, false, true, nil, nil, func(name string) (modified string, count int) {
+			switch name {
+			case "a.txt":
+				modified, count = "b.txt", 1
+			case "b.txt":
+				modified, count = "a.txt", 1
+			}
+			return
+		})
+		So(errors.Is(err, ErrRenameCycle), ShouldBeTrue)
+		So(err.Error(), ShouldContainSubstring, "a.txt -> ")
+	})
+
+	Convey("collisions are detected", t, func() {
+		dir := tMakeTree(t, "user.go", "account.go", "one/user.txt", "one/USER.txt")
+		_, err := FindAllRenames([]string{filepath.Join(dir, "user.go"), filepath.Join(dir, "account.go")}, false, false, nil, nil, tRename("user", "account"))
+		So(errors.Is(err, ErrRenameCollision), ShouldBeTrue)
+		_, err = FindAllRenames([]string{filepath.Join(dir, "one")}, false, true, nil, nil, func(name string) (modified string, count int) {
+			modified, count = StringInsensitive("user", "account", name)
+			return
+		})
+		So(errors.Is(err, ErrRenameCollision), ShouldBeTrue)
+		var re *RenameError
+		So(errors.As(err, &re), ShouldBeTrue)
+		So(filepath.Base(re.Target), ShouldEqual, "account.txt")
+
+		renames := Renames{{filepath.Join(dir, "user.go"), filepath.Join(dir, "account.go")}}
+		So(errors.Is(renames.Apply(), ErrRenameCollision), ShouldBeTrue)
+		renames = Renames{{filepath.Join(dir, "nope.go"), filepath.Join(dir, "other.go")}}
+		So(renames.Apply(), ShouldNotBeNil)
+	})
+
+	Convey("invalid names", t, func() {
+		dir := tMakeTree(t, "user.go")
+		_, err := FindAllRenames([]string{dir}, false, true, nil, nil, tRename("user.go", "a/b.go"))
+		So(errors.Is(err, ErrRenameInvalid), ShouldBeTrue)
+	})
+
+}
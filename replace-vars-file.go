@@ -0,0 +1,149 @@
+// Copyright (c) 2024  The Go-Curses Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package replace
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/go-corelibs/diff"
+)
+
+var (
+	ErrVarsTreeEscape = errors.New("rendered path escapes the destination")
+)
+
+// VarsFile uses Vars to ProcessFile, the `count` is the number of variable
+// references expanded
+func VarsFile(target string, replacements map[string]string) (original, modified string, count int, delta *diff.Diff, err error) {
+	original, modified, count, delta, err = ProcessFile(target, func(original string) (modified string, count int) {
+		modified, count, _ = newVarsExpander(MapsLookup(replacements), VarsOptions{}).run(original)
+		return
+	})
+	return
+}
+
+// VarsFileWith uses VarsWith to ProcessFile, any VarsError is returned after
+// the file has been completely expanded
+func VarsFileWith(target string, replacements map[string]string, options VarsOptions) (original, modified string, count int, delta *diff.Diff, err error) {
+	var verr error
+	original, modified, count, delta, err = ProcessFile(target, func(original string) (modified string, count int) {
+		modified, count, verr = newVarsExpander(MapsLookup(replacements), options).run(original)
+		return
+	})
+	if err == nil {
+		err = verr
+	}
+	return
+}
+
+// RenderVarsTree walks the `src` template directory, using
+// FindAllIncludedWith with the `find` options given (which are always
+// recursive), and writes each file found into the `dst` directory. The
+// relative path names and text file contents are expanded with VarsWith,
+// binary files are copied as-is and file permissions are preserved. When
+// FindOptions.FS is set, `src` is the name of a directory within that fs.FS
+// and `dst` is still a local directory
+//
+// RenderVarsTree returns the list of files `written` so far along with the
+// first error encountered, including any WalkErrors from finding the template
+// files and ErrVarsTreeEscape for expanded path names which would end up
+// outside of `dst`
+func RenderVarsTree(src, dst string, replacements map[string]string, options VarsOptions, find FindOptions) (written []string, err error) {
+	w := newWalker(find.FS)
+	find.Recurse = true
+
+	var files []string
+	if files, err = FindAllIncludedWith([]string{src}, find); err != nil {
+		return
+	}
+
+	for _, file := range files {
+		var target string
+		if target, err = renderVarsTreePath(src, dst, file, replacements, options, find.FS != nil); err != nil {
+			err = fmt.Errorf("%s: %w", file, err)
+			return
+		}
+
+		var data []byte
+		var mode fs.FileMode
+		if data, mode, err = readVarsTreeFile(w, find.FS, file); err != nil {
+			return
+		} else if isTextData(data) {
+			var expanded string
+			if expanded, err = VarsWith(string(data), replacements, options); err != nil {
+				err = fmt.Errorf("%s: %w", file, err)
+				return
+			}
+			data = []byte(expanded)
+		}
+
+		if err = os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return
+		} else if err = os.WriteFile(target, data, mode.Perm()); err != nil {
+			return
+		}
+		written = append(written, target)
+	}
+	return
+}
+
+// renderVarsTreePath returns the `dst` path of the template `file` found
+// within the `src` directory, with the relative path name expanded
+func renderVarsTreePath(src, dst, file string, replacements map[string]string, options VarsOptions, slashed bool) (target string, err error) {
+	var rel string
+	if slashed {
+		rel = strings.TrimPrefix(strings.TrimPrefix(file, src), "/")
+		if src == "." {
+			rel = file
+		}
+	} else if rel, err = filepath.Rel(src, file); err != nil {
+		return
+	}
+
+	if rel, err = VarsWith(filepath.ToSlash(rel), replacements, options); err != nil {
+		return
+	}
+
+	target = filepath.Join(dst, filepath.FromSlash(rel))
+	if check, ee := filepath.Rel(dst, target); ee != nil || check == "." || check == ".." || strings.HasPrefix(check, ".."+string(filepath.Separator)) {
+		err = fmt.Errorf("%w: %q", ErrVarsTreeEscape, rel)
+	}
+	return
+}
+
+// readVarsTreeFile returns the contents and permissions of the `file`
+func readVarsTreeFile(w walker, fsys fs.FS, file string) (data []byte, mode fs.FileMode, err error) {
+	var info fs.FileInfo
+	if fsys != nil {
+		info, err = fs.Stat(fsys, file)
+	} else {
+		info, err = os.Stat(file)
+	}
+	if err != nil {
+		return
+	} else if data, err = w.readFile(file); err != nil {
+		return
+	}
+	if mode = info.Mode(); mode.Perm() == 0 {
+		// fs.FS implementations like fstest.MapFS can have no permissions
+		mode = 0644
+	}
+	return
+}
@@ -0,0 +1,118 @@
+// Copyright (c) 2024  The Go-Curses Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package replace
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/go-corelibs/globs"
+)
+
+func TestVarsFile(t *testing.T) {
+
+	Convey("VarsFile", t, func() {
+		target := filepath.Join(t.TempDir(), "config.ini")
+		So(os.WriteFile(target, []byte("host=${host}\nport=${port:-80}\nuser=$user\n"), 0644), ShouldBeNil)
+
+		original, modified, count, delta, err := VarsFile(target, map[string]string{"host": "localhost"})
+		So(err, ShouldBeNil)
+		So(original, ShouldEqual, "host=${host}\nport=${port:-80}\nuser=$user\n")
+		So(modified, ShouldEqual, "host=localhost\nport=80\nuser=\n")
+		So(count, ShouldEqual, 3)
+		So(delta.Len(), ShouldEqual, 4)
+
+		_, modified, count, _, err = VarsFileWith(target, map[string]string{"host": "localhost"}, VarsOptions{Undefined: UndefinedKeep})
+		So(err, ShouldBeNil)
+		So(modified, ShouldEqual, "host=localhost\nport=80\nuser=$user\n")
+		So(count, ShouldEqual, 2)
+
+		_, modified, _, _, err = VarsFileWith(target, map[string]string{"host": "localhost"}, VarsOptions{Undefined: UndefinedError})
+		So(errors.Is(err, ErrVarUndefined), ShouldBeTrue)
+		So(modified, ShouldEqual, "host=localhost\nport=80\nuser=\n")
+
+		_, _, _, _, err = VarsFile(filepath.Join(t.TempDir(), "missing"), nil)
+		So(err, ShouldNotBeNil)
+	})
+
+	Convey("RenderVarsTree", t, func() {
+		src := tMakeTree(t,
+			"${name}/main.go",
+			"${name}/${name|snake}_test.go",
+			"README.md",
+			".hidden",
+			"skip.tmp",
+		)
+		So(os.WriteFile(filepath.Join(src, "${name}", "main.go"), []byte("package ${name}\n"), 0644), ShouldBeNil)
+		So(os.Chmod(filepath.Join(src, "${name}", "main.go"), 0755), ShouldBeNil)
+		So(os.WriteFile(filepath.Join(src, "logo.bin"), []byte("${name}\x00\x01"), 0644), ShouldBeNil)
+		dst := t.TempDir()
+
+		vars := map[string]string{"name": "myApp"}
+		exclude, _ := globs.Parse("*.tmp")
+		written, err := RenderVarsTree(src, dst, vars, VarsOptions{}, FindOptions{Exclude: exclude})
+		So(err, ShouldBeNil)
+		So(written, ShouldHaveLength, 4)
+
+		data, err := os.ReadFile(filepath.Join(dst, "myApp", "main.go"))
+		So(err, ShouldBeNil)
+		So(string(data), ShouldEqual, "package myApp\n")
+		info, _ := os.Stat(filepath.Join(dst, "myApp", "main.go"))
+		So(info.Mode().Perm(), ShouldEqual, os.FileMode(0755))
+		data, err = os.ReadFile(filepath.Join(dst, "myApp", "my_app_test.go"))
+		So(err, ShouldBeNil)
+		So(string(data), ShouldEqual, "myApp/my_app_test.go")
+		data, err = os.ReadFile(filepath.Join(dst, "logo.bin"))
+		So(err, ShouldBeNil)
+		So(string(data), ShouldEqual, "${name}\x00\x01")
+		So(filepath.Join(dst, "skip.tmp"), ShouldNotBeIn, written)
+		_, err = os.Stat(filepath.Join(dst, ".hidden"))
+		So(os.IsNotExist(err), ShouldBeTrue)
+	})
+
+	Convey("RenderVarsTree errors", t, func() {
+		src := tMakeTree(t, "${dir}/file.txt")
+		dst := t.TempDir()
+
+		_, err := RenderVarsTree(src, dst, map[string]string{"dir": "../../escaped"}, VarsOptions{}, FindOptions{})
+		So(errors.Is(err, ErrVarsTreeEscape), ShouldBeTrue)
+
+		_, err = RenderVarsTree(src, dst, map[string]string{}, VarsOptions{Undefined: UndefinedError}, FindOptions{})
+		So(errors.Is(err, ErrVarUndefined), ShouldBeTrue)
+
+		written, err := RenderVarsTree(src, dst, map[string]string{"dir": "a/b"}, VarsOptions{}, FindOptions{})
+		So(err, ShouldBeNil)
+		So(written, ShouldResemble, []string{filepath.Join(dst, "a", "b", "file.txt")})
+	})
+
+	Convey("RenderVarsTree with an fs.FS", t, func() {
+		fsys := fstest.MapFS{
+			"templates/${name}.txt": {Data: []byte("hello ${name}")},
+			"other/ignored.txt":     {Data: []byte("ignored")},
+		}
+		dst := t.TempDir()
+		written, err := RenderVarsTree("templates", dst, map[string]string{"name": "world"}, VarsOptions{}, FindOptions{FS: fsys})
+		So(err, ShouldBeNil)
+		So(written, ShouldResemble, []string{filepath.Join(dst, "world.txt")})
+		data, err := os.ReadFile(filepath.Join(dst, "world.txt"))
+		So(err, ShouldBeNil)
+		So(string(data), ShouldEqual, "hello world")
+	})
+}
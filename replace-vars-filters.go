@@ -0,0 +1,88 @@
+// Copyright (c) 2024  The Go-Curses Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package replace
+
+import (
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/go-corelibs/strcases"
+)
+
+// VarsFilterFn is the signature for functions used in Vars filter pipelines,
+// like `${name|upper}`
+type VarsFilterFn func(value string) (filtered string)
+
+var (
+	gVarsFilters     = map[string]VarsFilterFn{}
+	gVarsFiltersLock = &sync.RWMutex{}
+)
+
+func init() {
+	for name, c := range map[string]strcases.Case{
+		"lower":           strcases.LowerCase,
+		"upper":           strcases.UpperCase,
+		"camel":           strcases.CamelCase,
+		"lower_camel":     strcases.LowerCamelCase,
+		"kebab":           strcases.KebabCase,
+		"screaming_kebab": strcases.ScreamingKebabCase,
+		"snake":           strcases.SnakeCase,
+		"screaming_snake": strcases.ScreamingSnakeCase,
+	} {
+		RegisterVarsFilter(name, c.Apply)
+	}
+	RegisterVarsFilter("trim", strings.TrimSpace)
+	RegisterVarsFilter("quote", strconv.Quote)
+}
+
+// RegisterVarsFilter adds the filter `fn` to the global registry as `name`,
+// replacing any filter already registered with the same name. The built-in
+// filters are:
+//
+//	lower            lowercase
+//	upper            UPPERCASE
+//	camel            CamelCase
+//	lower_camel      lowerCamelCase
+//	kebab            kebab-case
+//	screaming_kebab  SCREAMING-KEBAB-CASE
+//	snake            snake_case
+//	screaming_snake  SCREAMING_SNAKE_CASE
+//	trim             remove leading and trailing space
+//	quote            Go-style double quoted string
+//
+// Filter names are ASCII letters, digits and underscores, anything else ends
+// the filter name. Registering a nil `fn` removes the filter
+func RegisterVarsFilter(name string, fn VarsFilterFn) {
+	gVarsFiltersLock.Lock()
+	defer gVarsFiltersLock.Unlock()
+	if fn == nil {
+		delete(gVarsFilters, name)
+		return
+	}
+	gVarsFilters[name] = fn
+}
+
+// lookupVarsFilter returns the filter `name` from the `filters` given,
+// falling back to the global registry
+func lookupVarsFilter(filters map[string]VarsFilterFn, name string) (fn VarsFilterFn, found bool) {
+	if fn, found = filters[name]; found {
+		return
+	}
+	gVarsFiltersLock.RLock()
+	defer gVarsFiltersLock.RUnlock()
+	fn, found = gVarsFilters[name]
+	return
+}
@@ -0,0 +1,127 @@
+// Copyright (c) 2024  The Go-Curses Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package replace
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestVarsFilters(t *testing.T) {
+	vars := map[string]string{
+		"name":  "hello world",
+		"space": "  padded  ",
+		"quote": `say "hi"`,
+	}
+
+	Convey("Built-in filters", t, func() {
+		for _, test := range []struct {
+			input    string
+			expected string
+		}{
+			{"${name|upper}", "HELLO WORLD"},
+			{"${name|lower}", "hello world"},
+			{"${name|snake}", "hello_world"},
+			{"${name|kebab}", "hello-world"},
+			{"${name|camel}", "HelloWorld"},
+			{"${name|lower_camel}", "helloWorld"},
+			{"${name|screaming_snake}", "HELLO_WORLD"},
+			{"${name|screaming_kebab}", "HELLO-WORLD"},
+			{"[${space|trim}]", "[padded]"},
+			{"${quote|quote}", `"say \"hi\""`},
+			{"${space|trim|snake|upper}", "PADDED"},
+			{"${unset|upper:-default value}", "DEFAULT VALUE"},
+			{"${name|upper#hello }", "WORLD"},
+			{"${name|kebab:+set}", "set"},
+			{"${name|upper-fallback}", "HELLO WORLD"},
+			{"${unset|upper-fallback}", "FALLBACK"},
+			{"${unset|upper=assigned}", "ASSIGNED"},
+			{"${name|upper+alternate}", "ALTERNATE"},
+			{"${unset|upper+alternate}", ""},
+			{"${name|upper?missing}", "HELLO WORLD"},
+			{"${name|}", "${name|}"},
+			{"${name|up per}", "${name|up per}"},
+			{"$name|upper", "hello world|upper"},
+		} {
+			So(Vars(test.input, vars), ShouldEqual, test.expected)
+		}
+	})
+
+	Convey("Custom filters", t, func() {
+		reverse := func(value string) (filtered string) {
+			for _, r := range value {
+				filtered = string(r) + filtered
+			}
+			return
+		}
+		RegisterVarsFilter("reverse", reverse)
+		defer RegisterVarsFilter("reverse", nil)
+		So(Vars("${name|reverse|upper}", vars), ShouldEqual, "DLROW OLLEH")
+
+		expanded, err := VarsWith("${name|upper}", vars, VarsOptions{
+			Filters: map[string]VarsFilterFn{"upper": strings.ToLower, "first": func(value string) (filtered string) {
+				filtered, _, _ = strings.Cut(value, " ")
+				return
+			}},
+		})
+		So(err, ShouldBeNil)
+		So(expanded, ShouldEqual, "hello world")
+		expanded, err = VarsWith("${name|first|reverse}", vars, VarsOptions{
+			Filters: map[string]VarsFilterFn{"first": func(value string) (filtered string) {
+				filtered, _, _ = strings.Cut(value, " ")
+				return
+			}},
+		})
+		So(err, ShouldBeNil)
+		So(expanded, ShouldEqual, "olleh")
+
+		RegisterVarsFilter("reverse", nil)
+		So(Vars("${name|reverse}", vars), ShouldEqual, "hello world")
+	})
+
+	Convey("Filters followed by operators", t, func() {
+		expanded, err := ExpandVars("${unset|upper?is missing}", vars)
+		So(expanded, ShouldEqual, "")
+		So(errors.Is(err, ErrVarUnset), ShouldBeTrue)
+		So(err.Error(), ShouldEqual, "unset (offset 0): is missing")
+		refs := ParseVars("${unset|lower_camel-fallback}", VarsOptions{})
+		So(refs, ShouldHaveLength, 1)
+		So(refs[0].Filters, ShouldResemble, []string{"lower_camel"})
+		So(refs[0].Operator, ShouldEqual, "-")
+		So(refs[0].Word, ShouldEqual, "fallback")
+	})
+
+	Convey("Unknown filters", t, func() {
+		So(Vars("${name|nope|upper}", vars), ShouldEqual, "HELLO WORLD")
+		So(VarsLenient("${name|nope|upper} $name", vars), ShouldEqual, "${name|nope|upper} hello world")
+
+		expanded, err := VarsStrict("a ${name|nope|upper}", vars)
+		So(expanded, ShouldEqual, "a HELLO WORLD")
+		So(errors.Is(err, ErrVarFilter), ShouldBeTrue)
+		So(err.Error(), ShouldEqual, `name (offset 2): unknown filter: "nope"`)
+	})
+
+	Convey("ParseVars filters", t, func() {
+		refs := ParseVars("${name|trim|upper:-x} $other", VarsOptions{})
+		So(refs, ShouldHaveLength, 2)
+		So(refs[0].Filters, ShouldResemble, []string{"trim", "upper"})
+		So(refs[0].Operator, ShouldEqual, ":-")
+		So(refs[0].Word, ShouldEqual, "x")
+		So(refs[1].Filters, ShouldBeNil)
+	})
+}
@@ -0,0 +1,79 @@
+// Copyright (c) 2024  The Go-Curses Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package replace
+
+import (
+	"unicode"
+)
+
+// VarsGrammar defines which characters make up variable names
+type VarsGrammar interface {
+	// IsVarFirst returns true if the rune can start a variable name
+	IsVarFirst(r rune) (valid bool)
+	// IsVarOther returns true if the rune can continue a variable name,
+	// `braced` is true for names within `${...}` references
+	IsVarOther(r rune, braced bool) (valid bool)
+}
+
+var (
+	// PosixVarsGrammar is the POSIX [3.235 Name] definition of underscores,
+	// digits and alphabetics from the portable character set, with the first
+	// character not being a digit
+	//
+	// [3.235 Name]: https://pubs.opengroup.org/onlinepubs/9699919799/basedefs/V1_chap03.html#tag_03_231
+	PosixVarsGrammar VarsGrammar = cPosixVarsGrammar{}
+
+	// UnicodeVarsGrammar is like PosixVarsGrammar except that any Unicode
+	// letter can start a name, and letters, digits and combining marks can
+	// continue one
+	UnicodeVarsGrammar VarsGrammar = cUnicodeVarsGrammar{}
+
+	// DottedVarsGrammar is like PosixVarsGrammar except that names within
+	// braces can also have periods, for example: `${db.host}`
+	DottedVarsGrammar VarsGrammar = cDottedVarsGrammar{}
+)
+
+type cPosixVarsGrammar struct{}
+
+func (cPosixVarsGrammar) IsVarFirst(r rune) (valid bool) {
+	valid = r == '_' || (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z')
+	return
+}
+
+func (g cPosixVarsGrammar) IsVarOther(r rune, braced bool) (valid bool) {
+	valid = g.IsVarFirst(r) || (r >= '0' && r <= '9')
+	return
+}
+
+type cUnicodeVarsGrammar struct{}
+
+func (cUnicodeVarsGrammar) IsVarFirst(r rune) (valid bool) {
+	valid = r == '_' || unicode.IsLetter(r)
+	return
+}
+
+func (cUnicodeVarsGrammar) IsVarOther(r rune, braced bool) (valid bool) {
+	valid = r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r) || unicode.IsMark(r)
+	return
+}
+
+type cDottedVarsGrammar struct {
+	cPosixVarsGrammar
+}
+
+func (g cDottedVarsGrammar) IsVarOther(r rune, braced bool) (valid bool) {
+	valid = g.cPosixVarsGrammar.IsVarOther(r, braced) || (braced && r == '.')
+	return
+}
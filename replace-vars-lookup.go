@@ -0,0 +1,63 @@
+// Copyright (c) 2024  The Go-Curses Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package replace
+
+import (
+	"os"
+	"path"
+)
+
+// VarsLookupFn is the signature for functions resolving variable names to
+// their values, `found` is false for undefined variables
+type VarsLookupFn func(name string) (value string, found bool)
+
+// MapsLookup returns a VarsLookupFn resolving names from the given `layers`
+// of maps, in the order given, the first layer defining a name wins
+func MapsLookup(layers ...map[string]string) (lookup VarsLookupFn) {
+	lookup = func(name string) (value string, found bool) {
+		for _, layer := range layers {
+			if value, found = layer[name]; found {
+				return
+			}
+		}
+		return
+	}
+	return
+}
+
+// EnvLookup returns a VarsLookupFn resolving names from the process
+// environment, limited to the names matching any of the `allow` patterns.
+// Patterns are either exact names or path.Match patterns like `APP_*`, no
+// names are allowed when `allow` is empty
+func EnvLookup(allow ...string) (lookup VarsLookupFn) {
+	lookup = func(name string) (value string, found bool) {
+		for _, pattern := range allow {
+			if matched, _ := path.Match(pattern, name); matched || pattern == name {
+				value, found = os.LookupEnv(name)
+				return
+			}
+		}
+		return
+	}
+	return
+}
+
+// VarsEnv is a convenience wrapper around VarsFunc and EnvLookup, expanding
+// the `input` with the process environment variables matching any of the
+// `allow` patterns. All other variables are undefined
+func VarsEnv(input string, allow []string, options VarsOptions) (expanded string, err error) {
+	expanded, err = VarsFunc(input, EnvLookup(allow...), options)
+	return
+}
@@ -0,0 +1,89 @@
+// Copyright (c) 2024  The Go-Curses Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package replace
+
+import (
+	"errors"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestVarsLookup(t *testing.T) {
+	Convey("VarsFunc", t, func() {
+		var calls []string
+		lookup := func(name string) (value string, found bool) {
+			calls = append(calls, name)
+			if found = name != "unset"; found {
+				value = "<" + name + ">"
+			}
+			return
+		}
+		expanded, err := VarsFunc("$one ${two} ${unset:-x} ${three:=3} $three", lookup, VarsOptions{})
+		So(err, ShouldBeNil)
+		So(expanded, ShouldEqual, "<one> <two> x <three> <three>")
+		So(calls, ShouldResemble, []string{"one", "two", "unset", "three", "three"})
+
+		expanded, err = VarsFunc("${unset:=set} $unset", lookup, VarsOptions{})
+		So(err, ShouldBeNil)
+		So(expanded, ShouldEqual, "set set")
+		So(calls[len(calls)-1], ShouldEqual, "unset")
+
+		expanded, err = VarsFunc("$unset", lookup, VarsOptions{Undefined: UndefinedError})
+		So(expanded, ShouldEqual, "")
+		So(errors.Is(err, ErrVarUndefined), ShouldBeTrue)
+	})
+
+	Convey("MapsLookup", t, func() {
+		lookup := MapsLookup(
+			map[string]string{"name": "first"},
+			map[string]string{"name": "second", "other": "second", "empty": ""},
+		)
+		value, found := lookup("name")
+		So(found, ShouldBeTrue)
+		So(value, ShouldEqual, "first")
+		value, found = lookup("other")
+		So(found, ShouldBeTrue)
+		So(value, ShouldEqual, "second")
+		value, found = lookup("empty")
+		So(found, ShouldBeTrue)
+		So(value, ShouldEqual, "")
+		_, found = lookup("missing")
+		So(found, ShouldBeFalse)
+		_, found = MapsLookup()("name")
+		So(found, ShouldBeFalse)
+	})
+
+	Convey("VarsEnv", t, func() {
+		t.Setenv("REPLACE_TEST_ONE", "one")
+		t.Setenv("REPLACE_TEST_TWO", "two")
+		t.Setenv("REPLACE_SECRET", "secret")
+
+		expanded, err := VarsEnv("$REPLACE_TEST_ONE $REPLACE_SECRET", nil, VarsOptions{})
+		So(err, ShouldBeNil)
+		So(expanded, ShouldEqual, " ")
+
+		expanded, err = VarsEnv("$REPLACE_TEST_ONE $REPLACE_SECRET", []string{"REPLACE_TEST_ONE"}, VarsOptions{})
+		So(err, ShouldBeNil)
+		So(expanded, ShouldEqual, "one ")
+
+		expanded, err = VarsEnv("$REPLACE_TEST_ONE-$REPLACE_TEST_TWO $REPLACE_SECRET", []string{"REPLACE_TEST_*"}, VarsOptions{Undefined: UndefinedKeep})
+		So(err, ShouldBeNil)
+		So(expanded, ShouldEqual, "one-two $REPLACE_SECRET")
+
+		_, err = VarsEnv("$REPLACE_SECRET", []string{"REPLACE_TEST_*"}, VarsOptions{Undefined: UndefinedError})
+		So(errors.Is(err, ErrVarUndefined), ShouldBeTrue)
+	})
+}
@@ -0,0 +1,375 @@
+// Copyright (c) 2024  The Go-Curses Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package replace
+
+import (
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// cVarRef is a single variable reference found within Vars input
+type cVarRef struct {
+	name      string   // variable name
+	nameStart int      // byte offset of the name
+	nested    bool     // name has nested ${...} references
+	source    string   // original text of the reference
+	start     int      // byte offset of the opening dollar sign
+	end       int      // byte offset just past the end of the reference
+	braced    bool     // ${name} form
+	length    bool     // ${#name} form
+	operator  string   // one of gVarOperators, empty for none
+	word      string   // unexpanded operator argument
+	wordStart int      // byte offset of the word
+	filters   []string // filter pipeline names
+}
+
+// handlesUnset returns true if the operator has specific behaviour for
+// variables which are not set
+func (ref *cVarRef) handlesUnset() (handled bool) {
+	switch ref.operator {
+	case ":-", "-", ":=", "=", ":?", "?", ":+", "+":
+		handled = true
+	}
+	return
+}
+
+// cVarsNode is either literal text or a variable reference
+type cVarsNode struct {
+	text string
+	ref  *cVarRef
+}
+
+// gVarOperators are the supported parameter expansion operators, two
+// character operators before their one character prefixes
+var gVarOperators = []string{
+	":-", ":=", ":?", ":+", "##", "%%",
+	"-", "=", "?", "+", "#", "%", ":",
+}
+
+// cVarsParser finds variable references according to the VarsOptions given
+type cVarsParser struct {
+	escapes VarsEscapes
+	grammar VarsGrammar
+	nested  bool
+}
+
+func newVarsParser(options VarsOptions) (p cVarsParser) {
+	p = cVarsParser{escapes: options.Escapes, grammar: options.Grammar, nested: options.Recursive}
+	if p.grammar == nil {
+		p.grammar = PosixVarsGrammar
+	}
+	return
+}
+
+// parse splits the `input` into literal text and variable references,
+// anything which is not a valid variable reference is literal text
+func (p cVarsParser) parse(input string) (nodes []cVarsNode) {
+	p.scan(input, func(node cVarsNode) {
+		if node.ref != nil {
+			ref := *node.ref
+			node.ref = &ref
+		}
+		nodes = append(nodes, node)
+	})
+	return
+}
+
+// special returns the characters which start escapes or references
+func (p cVarsParser) special() (chars string) {
+	if chars = "$"; p.escapes&EscapeBackslash != 0 {
+		chars = "$\\"
+	}
+	return
+}
+
+// plain returns true if the `input` has nothing to parse
+func (p cVarsParser) plain(input string) (plain bool) {
+	plain = !strings.ContainsAny(input, p.special())
+	return
+}
+
+// scan is the streaming form of parse, calling `fn` with each node in order.
+// The node references given to `fn` are only valid for the duration of the
+// call
+func (p cVarsParser) scan(input string, fn func(node cVarsNode)) {
+	special := p.special()
+
+	var ref cVarRef
+	var mark, idx int
+	flush := func(end int) {
+		if mark < end {
+			fn(cVarsNode{text: input[mark:end]})
+		}
+	}
+
+	for idx < len(input) {
+		next := strings.IndexAny(input[idx:], special)
+		if next < 0 {
+			break
+		}
+		idx += next
+
+		if size := p.escaped(input, idx); size > 0 {
+			// escape sequence, write the literal text so far and skip the
+			// escape character
+			flush(idx)
+			fn(cVarsNode{text: input[idx+1 : idx+size]})
+			idx += size
+			mark = idx
+			continue
+		} else if input[idx] != '$' {
+			// backslash not escaping anything
+			idx += 1
+			continue
+		}
+
+		if !p.parseRef(input, idx, &ref) {
+			// not a variable, the dollar sign is literal text
+			idx += 1
+			continue
+		}
+		flush(idx)
+		ref.source = input[idx:ref.end]
+		fn(cVarsNode{ref: &ref})
+		idx = ref.end
+		mark = idx
+	}
+	flush(len(input))
+}
+
+// escaped returns the size of the escape sequence at the `idx` offset of the
+// `input`, zero if there is no enabled escape sequence present. The escaped
+// character is always the last one of the sequence
+func (p cVarsParser) escaped(input string, idx int) (size int) {
+	if idx+1 < len(input) {
+		switch next := input[idx+1]; {
+		case input[idx] == '$' && next == '$' && p.escapes&EscapeDollar != 0:
+			size = 2
+		case input[idx] == '\\' && (next == '$' || next == '\\') && p.escapes&EscapeBackslash != 0:
+			size = 2
+		}
+	}
+	return
+}
+
+// parseRef parses the variable reference starting with the dollar sign at
+// the `start` offset of the `input` into the `ref` given, which is only
+// modified when `ok` is true
+func (p cVarsParser) parseRef(input string, start int, ref *cVarRef) (ok bool) {
+	idx := start + 1
+	if idx >= len(input) {
+		return
+	}
+
+	if input[idx] != '{' {
+		// $name
+		if name, size, _ := p.scanName(input[idx:], false); size > 0 {
+			*ref, ok = cVarRef{name: name, nameStart: idx, start: start, end: idx + size}, true
+		}
+		return
+	}
+
+	idx += 1
+	if idx < len(input) && input[idx] == '#' {
+		// ${#name}
+		if name, size, nested := p.scanName(input[idx+1:], true); size > 0 {
+			if end := idx + 1 + size; end < len(input) && input[end] == '}' {
+				*ref = cVarRef{name: name, nameStart: idx + 1, nested: nested, start: start, end: end + 1, braced: true, length: true}
+				ok = true
+				return
+			}
+		}
+	}
+
+	nameStart := idx
+	name, size, nested := p.scanName(input[idx:], true)
+	if size == 0 {
+		return
+	} else if idx += size; idx >= len(input) {
+		return
+	}
+
+	// ${name|filter|...}
+	var filters []string
+	for input[idx] == '|' {
+		filter, size := scanFilterName(input[idx+1:])
+		if size == 0 {
+			return
+		} else if idx += 1 + size; idx >= len(input) {
+			return
+		}
+		filters = append(filters, filter)
+	}
+
+	if input[idx] == '}' {
+		// ${name}
+		*ref = cVarRef{name: name, nameStart: nameStart, nested: nested, start: start, end: idx + 1, braced: true, filters: filters}
+		ok = true
+		return
+	}
+
+	// ${name<operator><word>}
+	var operator string
+	for _, op := range gVarOperators {
+		if strings.HasPrefix(input[idx:], op) {
+			operator = op
+			break
+		}
+	}
+	if operator == "" {
+		return
+	}
+	idx += len(operator)
+	if end := p.findClose(input, idx); end >= 0 {
+		*ref = cVarRef{
+			name:      name,
+			nameStart: nameStart,
+			nested:    nested,
+			start:     start,
+			end:       end + 1,
+			braced:    true,
+			operator:  operator,
+			word:      input[idx:end],
+			wordStart: idx,
+			filters:   filters,
+		}
+		ok = true
+	}
+	return
+}
+
+// findClose returns the offset of the closing brace matching an already
+// opened brace, skipping over any nested ${...} references and escapes
+func (p cVarsParser) findClose(input string, from int) (end int) {
+	var depth int
+	for idx := from; idx < len(input); idx++ {
+		if size := p.escaped(input, idx); size > 0 {
+			idx += size - 1
+			continue
+		}
+		switch input[idx] {
+		case '$':
+			if idx+1 < len(input) && input[idx+1] == '{' {
+				depth += 1
+				idx += 1
+			}
+		case '}':
+			if depth == 0 {
+				end = idx
+				return
+			}
+			depth -= 1
+		}
+	}
+	end = -1
+	return
+}
+
+// scanName returns the variable name at the start of `input`, `size` is zero
+// if there is no valid name present. When the parser allows nested names,
+// braced names can include ${...} references and `nested` is true if any were
+// found
+func (p cVarsParser) scanName(input string, braced bool) (name string, size int, nested bool) {
+	if _, posix := p.grammar.(cPosixVarsGrammar); posix && !p.nested {
+		// fast path for the default grammar, which is ASCII only
+		for size < len(input) && isPosixVarByte(input[size], size == 0) {
+			size += 1
+		}
+		name = input[:size]
+		return
+	}
+	for size < len(input) {
+		if braced && p.nested && strings.HasPrefix(input[size:], "${") {
+			if end := p.findClose(input, size+2); end > 0 {
+				size, nested = end+1, true
+				continue
+			}
+			break
+		}
+		r, width := utf8.DecodeRuneInString(input[size:])
+		if size == 0 && !p.grammar.IsVarFirst(r) {
+			break
+		} else if size > 0 && !p.grammar.IsVarOther(r, braced) {
+			break
+		}
+		size += width
+	}
+	name = input[:size]
+	return
+}
+
+// isPosixVarByte is the byte form of the PosixVarsGrammar
+func isPosixVarByte(c byte, first bool) (valid bool) {
+	valid = c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (!first && c >= '0' && c <= '9')
+	return
+}
+
+// scanFilterName returns the filter name at the start of `input`, filter
+// names are ASCII letters, digits and underscores so that operators such as
+// `-` can directly follow them
+func scanFilterName(input string) (name string, size int) {
+	for size < len(input) {
+		if c := input[size]; c == '_' || (c >= '0' && c <= '9') || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') {
+			size += 1
+			continue
+		}
+		break
+	}
+	name = input[:size]
+	return
+}
+
+// compileVarPattern converts a shell pattern into an anchored regular
+// expression, supporting the `*`, `?` and `[...]` (with `!` or `^` negation)
+// pattern matching notation
+func compileVarPattern(pattern string) (rx *regexp.Regexp, err error) {
+	var buffer strings.Builder
+	buffer.WriteString(`(?s)^`)
+	for idx := 0; idx < len(pattern); {
+		r, width := utf8.DecodeRuneInString(pattern[idx:])
+		switch r {
+		case '*':
+			buffer.WriteString(`.*`)
+		case '?':
+			buffer.WriteString(`.`)
+		case '\\':
+			if idx+width < len(pattern) {
+				next, size := utf8.DecodeRuneInString(pattern[idx+width:])
+				buffer.WriteString(regexp.QuoteMeta(string(next)))
+				width += size
+			} else {
+				buffer.WriteString(`\\`)
+			}
+		case '[':
+			if end := strings.IndexByte(pattern[idx+1:], ']'); end > 0 {
+				class := pattern[idx+1 : idx+1+end]
+				if class[0] == '!' || class[0] == '^' {
+					class = "^" + class[1:]
+				}
+				buffer.WriteString("[" + strings.ReplaceAll(class, `\`, `\\`) + "]")
+				width = end + 2
+			} else {
+				buffer.WriteString(`\[`)
+			}
+		default:
+			buffer.WriteString(regexp.QuoteMeta(string(r)))
+		}
+		idx += width
+	}
+	buffer.WriteString(`$`)
+	rx, err = regexp.Compile(buffer.String())
+	return
+}
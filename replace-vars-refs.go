@@ -0,0 +1,109 @@
+// Copyright (c) 2024  The Go-Curses Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package replace
+
+import (
+	"sort"
+)
+
+// VarRef describes a single variable reference found by ParseVars
+type VarRef struct {
+	// Name is the variable name, for nested names (see
+	// VarsOptions.Recursive) this is the unexpanded name
+	Name string
+	// Dynamic is true when the Name has nested references, like
+	// `${prefix_${env}}`
+	Dynamic bool
+	// Source is the original text of the entire reference
+	Source string
+	// Start is the byte offset of the reference's dollar sign
+	Start int
+	// End is the byte offset just past the end of the reference
+	End int
+	// Braced is true for references using the `${name}` form
+	Braced bool
+	// Length is true for `${#name}` references
+	Length bool
+	// Operator is the parameter expansion operator used, if any
+	Operator string
+	// Word is the unexpanded text following the Operator, this is the
+	// default value for the `:-` and `-` operators
+	Word string
+	// Filters are the names of the filter pipeline, if any
+	Filters []string
+	// Nested are the references found within the Name and Word
+	Nested []VarRef
+}
+
+// ParseVars returns all the variable references found within the given
+// `input`, using the same parser as VarsWith does for the `options` given.
+// Only the outermost references are returned, references within operator
+// words and nested names are listed in each VarRef.Nested
+func ParseVars(input string, options VarsOptions) (refs []VarRef) {
+	refs = newVarsParser(options).refs(input, 0)
+	return
+}
+
+// VarNames returns the sorted list of unique variable names referenced within
+// the given `input`, including those nested within other references. Dynamic
+// names are not included as their actual names are only known after expansion
+func VarNames(input string, options VarsOptions) (names []string) {
+	unique := make(map[string]struct{})
+	var walk func(refs []VarRef)
+	walk = func(refs []VarRef) {
+		for _, ref := range refs {
+			if !ref.Dynamic {
+				unique[ref.Name] = struct{}{}
+			}
+			walk(ref.Nested)
+		}
+	}
+	walk(ParseVars(input, options))
+	for name := range unique {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return
+}
+
+// refs returns the VarRef list for the `input`, `base` is the offset of
+// `input` within the original input given to ParseVars
+func (p cVarsParser) refs(input string, base int) (refs []VarRef) {
+	for _, node := range p.parse(input) {
+		if node.ref == nil {
+			continue
+		}
+		ref := VarRef{
+			Name:     node.ref.name,
+			Source:   node.ref.source,
+			Start:    base + node.ref.start,
+			End:      base + node.ref.end,
+			Braced:   node.ref.braced,
+			Length:   node.ref.length,
+			Operator: node.ref.operator,
+			Word:     node.ref.word,
+			Dynamic:  node.ref.nested,
+			Filters:  node.ref.filters,
+		}
+		if node.ref.nested {
+			ref.Nested = append(ref.Nested, p.refs(node.ref.name, base+node.ref.nameStart)...)
+		}
+		if node.ref.operator != "" {
+			ref.Nested = append(ref.Nested, p.refs(node.ref.word, base+node.ref.wordStart)...)
+		}
+		refs = append(refs, ref)
+	}
+	return
+}
@@ -0,0 +1,96 @@
+// Copyright (c) 2024  The Go-Curses Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package replace
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestVarsRefs(t *testing.T) {
+	Convey("ParseVars", t, func() {
+		So(ParseVars("", VarsOptions{}), ShouldBeEmpty)
+		So(ParseVars("no variables $ here", VarsOptions{}), ShouldBeEmpty)
+
+		refs := ParseVars("$HOME/${dir:-$default} ${#name} $$x", VarsOptions{})
+		So(refs, ShouldResemble, []VarRef{
+			{Name: "HOME", Source: "$HOME", Start: 0, End: 5},
+			{
+				Name:     "dir",
+				Source:   "${dir:-$default}",
+				Start:    6,
+				End:      22,
+				Braced:   true,
+				Operator: ":-",
+				Word:     "$default",
+				Nested: []VarRef{
+					{Name: "default", Source: "$default", Start: 13, End: 21},
+				},
+			},
+			{Name: "name", Source: "${#name}", Start: 23, End: 31, Braced: true, Length: true},
+			{Name: "x", Source: "$x", Start: 33, End: 35},
+		})
+
+		refs = ParseVars("$$x \\$y", VarsOptions{Escapes: EscapeDollar | EscapeBackslash})
+		So(refs, ShouldBeEmpty)
+
+		refs = ParseVars("${db.host}", VarsOptions{Grammar: DottedVarsGrammar})
+		So(refs, ShouldHaveLength, 1)
+		So(refs[0].Name, ShouldEqual, "db.host")
+		So(ParseVars("${db.host}", VarsOptions{}), ShouldBeEmpty)
+
+		refs = ParseVars("a ${prefix_${env}}", VarsOptions{Recursive: true})
+		So(refs, ShouldResemble, []VarRef{
+			{
+				Name:    "prefix_${env}",
+				Dynamic: true,
+				Source:  "${prefix_${env}}",
+				Start:   2,
+				End:     18,
+				Braced:  true,
+				Nested: []VarRef{
+					{Name: "env", Source: "${env}", Start: 11, End: 17, Braced: true},
+				},
+			},
+		})
+	})
+
+	Convey("ParseVars agrees with VarsWith", t, func() {
+		input := "$a ${b} $$c \\$d ${e.f} $é ${unclosed $ok ${bad!op} $1 $"
+		for _, options := range []VarsOptions{
+			{},
+			{Grammar: UnicodeVarsGrammar},
+			{Grammar: DottedVarsGrammar},
+		} {
+			marked := input
+			refs := ParseVars(input, options)
+			values := map[string]string{}
+			for idx := len(refs) - 1; idx >= 0; idx-- {
+				values[refs[idx].Name] = "<" + refs[idx].Name + ">"
+				marked = marked[:refs[idx].Start] + values[refs[idx].Name] + marked[refs[idx].End:]
+			}
+			expanded, err := VarsWith(input, values, options)
+			So(err, ShouldBeNil)
+			So(expanded, ShouldEqual, marked)
+		}
+	})
+
+	Convey("VarNames", t, func() {
+		So(VarNames("", VarsOptions{}), ShouldBeEmpty)
+		So(VarNames("$b $a ${c:-${a}} ${d:+$e} $b", VarsOptions{}), ShouldResemble, []string{"a", "b", "c", "d", "e"})
+		So(VarNames("${prefix_${env}}", VarsOptions{Recursive: true}), ShouldResemble, []string{"env"})
+	})
+}
@@ -15,9 +15,118 @@
 package replace
 
 import (
-	"unicode"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
 )
 
+var (
+	ErrVarUnset     = errors.New("parameter null or not set")
+	ErrVarSyntax    = errors.New("bad substitution")
+	ErrVarUndefined = errors.New("undefined variable")
+	ErrVarCycle     = errors.New("reference cycle")
+	ErrVarFilter    = errors.New("unknown filter")
+)
+
+// VarsUndefined specifies how references to undefined variables are handled,
+// references using one of the operators which handle unset variables (like
+// `${name:-word}`) are never considered undefined
+type VarsUndefined uint8
+
+const (
+	// UndefinedEmpty replaces undefined references with empty strings
+	UndefinedEmpty VarsUndefined = iota
+	// UndefinedError is UndefinedEmpty and also reports each undefined
+	// reference with an ErrVarUndefined VarError
+	UndefinedError
+	// UndefinedKeep leaves undefined references untouched
+	UndefinedKeep
+)
+
+// VarsEscapes is a bitmask of the escape sequences recognized for writing
+// literal dollar signs
+type VarsEscapes uint8
+
+const (
+	// EscapeDollar recognizes `$$` as a literal `$`
+	EscapeDollar VarsEscapes = 1 << iota
+	// EscapeBackslash recognizes `\$` as a literal `$` and `\\` as a literal
+	// `\`, any other backslashes are left as-is
+	EscapeBackslash
+)
+
+// VarsOptions configures the behaviour of VarsWith, the zero value is the
+// same as Vars
+type VarsOptions struct {
+	// Grammar specifies what a variable name is, defaults to the
+	// PosixVarsGrammar when nil
+	Grammar VarsGrammar
+	// Undefined specifies how references to undefined variables are handled,
+	// and also how unknown filters are handled: UndefinedEmpty ignores them,
+	// UndefinedKeep leaves the entire reference untouched and UndefinedError
+	// reports them with an ErrVarFilter VarError
+	Undefined VarsUndefined
+	// Escapes specifies which escape sequences produce literal dollar signs
+	Escapes VarsEscapes
+	// Recursive expands any references found within variable values, until
+	// there are none left, and allows braced names to have nested references
+	// like `${prefix_${env}}`. Reference cycles are reported as ErrVarCycle
+	// errors, naming the path of the cycle, and errors found within values
+	// have the offset of the outermost reference
+	Recursive bool
+	// Filters are additional filters for use in pipelines, taking precedence
+	// over any filters registered with RegisterVarsFilter
+	Filters map[string]VarsFilterFn
+}
+
+// VarError describes a problem expanding a single variable reference
+type VarError struct {
+	// Name is the variable name
+	Name string
+	// Offset is the byte offset of the reference within the input
+	Offset int
+	// Message is an optional description of the error, used instead of
+	// Err.Error() when not empty
+	Message string
+	// Err is the reason for the error, one of the ErrVar errors
+	Err error
+}
+
+func (e *VarError) Error() (message string) {
+	if message = e.Message; message == "" {
+		message = e.Err.Error()
+	}
+	message = fmt.Sprintf("%s (offset %d): %s", e.Name, e.Offset, message)
+	return
+}
+
+func (e *VarError) Unwrap() (err error) {
+	err = e.Err
+	return
+}
+
+// VarsError is the list of all VarError instances encountered during a
+// single expansion of input
+type VarsError []*VarError
+
+func (e VarsError) Error() (message string) {
+	messages := make([]string, len(e))
+	for idx, ve := range e {
+		messages[idx] = ve.Error()
+	}
+	message = strings.Join(messages, "; ")
+	return
+}
+
+func (e VarsError) Unwrap() (errs []error) {
+	for _, ve := range e {
+		errs = append(errs, ve)
+	}
+	return
+}
+
 // Vars searches through `input` for variables in the form of `$Name` or
 // `${Name}` and replaces them with the corresponding `replacements` value.
 // Missing keys are replaced with empty strings
@@ -27,113 +136,351 @@ import (
 //	a word consisting solely of underscores, digits, and alphabetics from
 //	the portable character set. The first character of a name is not a digit
 //
+// VarsWith supports other variable name definitions, see VarsGrammar for the
+// available choices
+//
+// Vars also supports the POSIX [Parameter Expansion] operators:
+//
+//	${name:-word}  use word if name is unset or empty
+//	${name-word}   use word if name is unset
+//	${name:=word}  assign word to name if name is unset or empty
+//	${name=word}   assign word to name if name is unset
+//	${name:?word}  error with word if name is unset or empty
+//	${name?word}   error with word if name is unset
+//	${name:+word}  use word if name is set and not empty
+//	${name+word}   use word if name is set
+//	${#name}       the number of characters in the value of name
+//	${name#word}   remove the shortest prefix matching the word pattern
+//	${name##word}  remove the longest prefix matching the word pattern
+//	${name%word}   remove the shortest suffix matching the word pattern
+//	${name%%word}  remove the longest suffix matching the word pattern
+//
+// along with the common `${name:offset}` and `${name:offset:length}`
+// substring extension, counted in characters. The words are expanded before
+// use and assignments only last for the duration of the call, the
+// `replacements` are never modified. Errors are ignored by Vars, see
+// ExpandVars for receiving them
+//
+// Braced references can also have a pipeline of filters following the name,
+// which are applied in order to the final value of the reference:
+//
+//	${name|trim|upper}
+//	${name|snake:-default name}
+//
+// The filters available are listed with RegisterVarsFilter, which is also
+// used to add custom filters
+//
 // [3.235 Name]: https://pubs.opengroup.org/onlinepubs/9699919799/basedefs/V1_chap03.html#tag_03_231
+// [Parameter Expansion]: https://pubs.opengroup.org/onlinepubs/9699919799/utilities/V3_chap02.html#tag_18_06_02
 func Vars(input string, replacements map[string]string) (expanded string) {
+	expanded, _ = ExpandVars(input, replacements)
+	return
+}
 
-	s := struct {
-		opened bool
-		braced bool
-		key    string
-		source string
-	}{}
-
-	for _, r := range input {
-		char := string(r)
-
-		if s.opened {
-			// dollar sign was previously found
-			s.source += char // always track the original text
-			keyLen := len(s.key)
-
-			if s.braced {
-				// dollar sign was followed by an opening brace, must have a
-				// closing brace in order for this to be an actual variable
-				// instance
-				if keyLen == 0 {
-					if isVarFirstCharacter(r) {
-						// valid first variable name character detected
-						s.key += char
-						continue
-					}
-					// not a valid variable name
-					expanded += s.source
-				} else if isVarOtherCharacter(r) {
-					// valid subsequent variable name character detected
-					s.key += char
-					continue
-				} else if r == '}' {
-					// closing brace detected
-					if v, ok := replacements[s.key]; ok {
-						// replacement value ready
-						expanded += v
-					}
-				} else {
-					// this is not a valid variable instance
-					expanded += s.source
-				}
-				// cleanup the current state
-				s.opened = false
-				s.braced = false
-				s.source = ""
-				s.key = ""
-				continue
-			}
+// ExpandVars is like Vars except that any `${name:?word}` and
+// `${name?word}` failures, along with any malformed substring or pattern
+// words, are returned as a VarsError. All references are expanded before
+// returning, references with errors expand to empty strings
+func ExpandVars(input string, replacements map[string]string) (expanded string, err error) {
+	expanded, err = VarsWith(input, replacements, VarsOptions{})
+	return
+}
 
-			if keyLen == 0 {
-				if r == '{' {
-					s.braced = true
-					continue
-				} else if isVarFirstCharacter(r) {
-					// valid first variable name character detected
-					s.key += char
-					continue
-				}
-				// this is not a valid variable instance
-				expanded += s.source
-			} else if isVarOtherCharacter(r) {
-				// valid subsequent variable name character detected
-				s.key += char
-				continue
-			}
+// VarsWith is the VarsOptions form of ExpandVars
+func VarsWith(input string, replacements map[string]string, options VarsOptions) (expanded string, err error) {
+	expanded, err = VarsFunc(input, MapsLookup(replacements), options)
+	return
+}
 
-			if v, ok := replacements[s.key]; ok {
-				// replacement value ready
-				expanded += v
-				expanded += char
-			}
+// VarsFunc is like VarsWith except that variable values are resolved with the
+// given `lookup` function instead of a map, `lookup` is called for each
+// reference and is not called again for variables assigned with the `:=` or
+// `=` operators
+func VarsFunc(input string, lookup VarsLookupFn, options VarsOptions) (expanded string, err error) {
+	expanded, _, err = newVarsExpander(lookup, options).run(input)
+	return
+}
 
-			// cleanup the current state
-			s.opened = false
-			s.braced = false
-			s.source = ""
-			s.key = ""
-			continue
+// VarsStrict is like ExpandVars except that references to undefined
+// variables are also reported, the VarsError returned lists every undefined
+// variable name along with the byte offset of the reference
+func VarsStrict(input string, replacements map[string]string) (expanded string, err error) {
+	expanded, err = VarsWith(input, replacements, VarsOptions{Undefined: UndefinedError})
+	return
+}
+
+// VarsLenient is like Vars except that references to undefined variables are
+// left untouched instead of being removed, allowing for templates to be
+// expanded in multiple passes
+func VarsLenient(input string, replacements map[string]string) (expanded string) {
+	expanded, _ = VarsWith(input, replacements, VarsOptions{Undefined: UndefinedKeep})
+	return
+}
+
+type cVarsExpander struct {
+	lookup    VarsLookupFn
+	parser    cVarsParser
+	undefined VarsUndefined
+	recursive bool
+	filters   map[string]VarsFilterFn
+	assigned  map[string]string
+	resolved  map[string]string
+	stack     []string // names being resolved
+	origin    int      // offset of the outermost reference being resolved
+	errs      VarsError
+}
+
+func newVarsExpander(lookup VarsLookupFn, options VarsOptions) (e *cVarsExpander) {
+	e = &cVarsExpander{
+		lookup:    lookup,
+		parser:    newVarsParser(options),
+		undefined: options.Undefined,
+		recursive: options.Recursive,
+		filters:   options.Filters,
+	}
+	return
+}
+
+func (e *cVarsExpander) run(input string) (expanded string, count int, err error) {
+	expanded, count = e.expand(input, 0)
+	if len(e.errs) > 0 {
+		err = e.errs
+	}
+	return
+}
+
+func (e *cVarsExpander) get(ref *cVarRef, base int) (value string, found bool) {
+	if value, found = e.assigned[ref.name]; found {
+		return
+	} else if value, found = e.lookup(ref.name); found && e.recursive {
+		value = e.resolve(ref, base, value)
+	}
+	return
+}
+
+// resolve returns the fully expanded `value` of the variable referenced,
+// reporting an ErrVarCycle if the variable is already being resolved
+func (e *cVarsExpander) resolve(ref *cVarRef, base int, value string) (resolved string) {
+	if cached, ok := e.resolved[ref.name]; ok {
+		resolved = cached
+		return
+	}
+	for idx, name := range e.stack {
+		if name == ref.name {
+			cycle := append(append([]string{}, e.stack[idx:]...), ref.name)
+			e.fail(ref, base, strings.Join(cycle, " -> "), ErrVarCycle)
+			return
 		}
+	}
+	if len(e.stack) == 0 {
+		e.origin = base + ref.start
+	}
+	e.stack = append(e.stack, ref.name)
+	resolved, _ = e.expand(value, base)
+	e.stack = e.stack[:len(e.stack)-1]
+	if e.resolved == nil {
+		e.resolved = make(map[string]string)
+	}
+	e.resolved[ref.name] = resolved
+	return
+}
 
-		if s.opened = r == '$'; s.opened {
-			// variable opening detected
-			s.source += char
-			continue
+func (e *cVarsExpander) set(name, value string) {
+	if e.assigned == nil {
+		e.assigned = make(map[string]string)
+	}
+	e.assigned[name] = value
+}
+
+func (e *cVarsExpander) fail(ref *cVarRef, base int, message string, err error) {
+	offset := base + ref.start
+	if len(e.stack) > 0 {
+		offset = e.origin
+	}
+	e.errs = append(e.errs, &VarError{
+		Name:    ref.name,
+		Offset:  offset,
+		Message: message,
+		Err:     err,
+	})
+}
+
+// expand returns the `input` with all variable references expanded, `base`
+// is the offset of `input` within the original input given to Vars. The
+// `count` is the number of references which were changed by expansion
+func (e *cVarsExpander) expand(input string, base int) (expanded string, count int) {
+	if e.parser.plain(input) {
+		expanded = input
+		return
+	}
+	var buffer strings.Builder
+	buffer.Grow(len(input))
+	e.parser.scan(input, func(node cVarsNode) {
+		if node.ref == nil {
+			buffer.WriteString(node.text)
+			return
 		}
+		value := e.expandRef(node.ref, base)
+		if value != node.ref.source {
+			count += 1
+		}
+		buffer.WriteString(value)
+	})
+	expanded = buffer.String()
+	return
+}
 
-		// actual content
-		expanded += char
+func (e *cVarsExpander) expandRef(ref *cVarRef, base int) (value string) {
+	if ref.nested {
+		resolved := *ref
+		resolved.name, _ = e.expand(ref.name, base+ref.nameStart)
+		ref = &resolved
+	}
+	value, found := e.get(ref, base)
+	empty := !found || value == ""
+	if !found && !ref.handlesUnset() {
+		switch e.undefined {
+		case UndefinedKeep:
+			value = ref.source
+			return
+		case UndefinedError:
+			e.fail(ref, base, "", ErrVarUndefined)
+		}
+	}
+	word := func() (expanded string) {
+		expanded, _ = e.expand(ref.word, base+ref.wordStart)
+		return
+	}
+
+	switch ref.operator {
+	case ":-", "-":
+		if !found || (empty && ref.operator == ":-") {
+			value = word()
+		}
+	case ":=", "=":
+		if !found || (empty && ref.operator == ":=") {
+			value = word()
+			e.set(ref.name, value)
+		}
+	case ":?", "?":
+		if !found || (empty && ref.operator == ":?") {
+			e.fail(ref, base, word(), ErrVarUnset)
+			value = ""
+		}
+	case ":+", "+":
+		if found && (!empty || ref.operator == "+") {
+			value = word()
+		} else {
+			value = ""
+		}
+	case "#", "##", "%", "%%":
+		value = e.trimPattern(ref, base, value, word())
+	case ":":
+		value = e.substring(ref, base, value, word())
 	}
 
-	if s.key != "" {
-		if v, ok := replacements[s.key]; ok {
-			expanded += v
+	if ref.length {
+		value = strconv.Itoa(utf8.RuneCountInString(value))
+	}
+	for _, name := range ref.filters {
+		if fn, ok := lookupVarsFilter(e.filters, name); ok {
+			value = fn(value)
+			continue
+		}
+		switch e.undefined {
+		case UndefinedKeep:
+			value = ref.source
+			return
+		case UndefinedError:
+			e.fail(ref, base, fmt.Sprintf("%s: %q", ErrVarFilter, name), ErrVarFilter)
 		}
 	}
 	return
 }
 
-func isVarFirstCharacter(r rune) (valid bool) {
-	valid = r == '_' || unicode.IsLower(r)
+// trimPattern removes the prefix (# and ##) or suffix (% and %%) of the
+// `value` matching the `pattern`
+func (e *cVarsExpander) trimPattern(ref *cVarRef, base int, value, pattern string) (trimmed string) {
+	trimmed = value
+	rx, err := compileVarPattern(pattern)
+	if err != nil {
+		e.fail(ref, base, "", ErrVarSyntax)
+		return
+	}
+	// candidate cut points, at character boundaries
+	cuts := []int{0}
+	for idx := range value {
+		if idx > 0 {
+			cuts = append(cuts, idx)
+		}
+	}
+	cuts = append(cuts, len(value))
+	last := len(cuts) - 1
+	for i := range cuts {
+		switch ref.operator {
+		case "#": // shortest prefix
+			if cut := cuts[i]; rx.MatchString(value[:cut]) {
+				trimmed = value[cut:]
+				return
+			}
+		case "##": // longest prefix
+			if cut := cuts[last-i]; rx.MatchString(value[:cut]) {
+				trimmed = value[cut:]
+				return
+			}
+		case "%": // shortest suffix
+			if cut := cuts[last-i]; rx.MatchString(value[cut:]) {
+				trimmed = value[:cut]
+				return
+			}
+		case "%%": // longest suffix
+			if cut := cuts[i]; rx.MatchString(value[cut:]) {
+				trimmed = value[:cut]
+				return
+			}
+		}
+	}
 	return
 }
 
-func isVarOtherCharacter(r rune) (valid bool) {
-	valid = r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+// substring returns the characters of `value` selected by the `spec` given,
+// in the form of "offset" or "offset:length" where negative offsets count
+// from the end of `value` and negative lengths are the number of characters
+// to leave off the end
+func (e *cVarsExpander) substring(ref *cVarRef, base int, value, spec string) (substr string) {
+	runes := []rune(value)
+	size := len(runes)
+
+	offsetSpec, lengthSpec, hasLength := strings.Cut(spec, ":")
+	offset, err := strconv.Atoi(strings.TrimSpace(offsetSpec))
+	if err != nil {
+		e.fail(ref, base, "", ErrVarSyntax)
+		return
+	}
+	if offset < 0 {
+		offset += size
+	}
+	if offset < 0 || offset > size {
+		return
+	}
+
+	end := size
+	if hasLength {
+		var length int
+		if length, err = strconv.Atoi(strings.TrimSpace(lengthSpec)); err != nil {
+			e.fail(ref, base, "", ErrVarSyntax)
+			return
+		}
+		if length < 0 {
+			end = size + length
+		} else {
+			end = min(size, offset+length)
+		}
+		if end < offset {
+			e.fail(ref, base, "substring expression < 0", ErrVarSyntax)
+			return
+		}
+	}
+	substr = string(runes[offset:end])
 	return
 }
@@ -15,6 +15,8 @@
 package replace
 
 import (
+	"errors"
+	"strings"
 	"testing"
 
 	. "github.com/smartystreets/goconvey/convey"
@@ -50,4 +52,285 @@ func TestVars(t *testing.T) {
 		So(Vars("$text", map[string]string{"text": "string"}), ShouldEqual, "string")
 		So(Vars("${text}", map[string]string{"text": "string"}), ShouldEqual, "string")
 	})
+
+	Convey("Parameter expansion operators", t, func() {
+		vars := map[string]string{
+			"set":   "value",
+			"empty": "",
+			"path":  "/usr/local/lib/libthing.so.1",
+			"word":  "naïve",
+		}
+		for input, expected := range map[string]string{
+			"${set:-default}":   "value",
+			"${empty:-default}": "default",
+			"${unset:-default}": "default",
+			"${empty-default}":  "",
+			"${unset-default}":  "default",
+			"${unset:-$set}":    "value",
+			"${unset:-${set}!}": "value!",
+			"${set:+alt}":       "alt",
+			"${empty:+alt}":     "",
+			"${empty+alt}":      "alt",
+			"${unset+alt}":      "",
+			"${#set}":           "5",
+			"${#word}":          "5",
+			"${#unset}":         "0",
+			"${path#*/}":        "usr/local/lib/libthing.so.1",
+			"${path##*/}":       "libthing.so.1",
+			"${path%.*}":        "/usr/local/lib/libthing.so",
+			"${path%%.*}":       "/usr/local/lib/libthing",
+			"${path#/usr}":      "/local/lib/libthing.so.1",
+			"${path#nope}":      "/usr/local/lib/libthing.so.1",
+			"${path##*[!a-z]}":  "",
+			"${word%?}":         "naïv",
+			"${set:1}":          "alue",
+			"${set:1:3}":        "alu",
+			"${set: -2}":        "ue",
+			"${set:1:-1}":       "alu",
+			"${set:9}":          "",
+			"${word:2:1}":       "ï",
+			"${set?}":           "value",
+		} {
+			So(Vars(input, vars), ShouldEqual, expected)
+		}
+	})
+
+	Convey("Assignment operators", t, func() {
+		vars := map[string]string{"empty": ""}
+		So(Vars("${unset:=one} $unset", vars), ShouldEqual, "one one")
+		So(Vars("${empty=one}[$empty]", vars), ShouldEqual, "[]")
+		So(Vars("${empty:=two} $empty", vars), ShouldEqual, "two two")
+		So(vars, ShouldResemble, map[string]string{"empty": ""})
+	})
+
+	Convey("Malformed operators", t, func() {
+		So(Vars("${set:-default", map[string]string{"set": "v"}), ShouldEqual, "${set:-default")
+		So(Vars("${set^^}", map[string]string{"set": "v"}), ShouldEqual, "${set^^}")
+		So(Vars("${#}", nil), ShouldEqual, "${#}")
+	})
+
+	Convey("ExpandVars errors", t, func() {
+		vars := map[string]string{"set": "value", "empty": ""}
+		expanded, err := ExpandVars("a ${set:?} b", vars)
+		So(err, ShouldBeNil)
+		So(expanded, ShouldEqual, "a value b")
+
+		expanded, err = ExpandVars("a ${empty:?must be set} b ${unset?}", vars)
+		So(expanded, ShouldEqual, "a  b ")
+		So(errors.Is(err, ErrVarUnset), ShouldBeTrue)
+		var ve VarsError
+		So(errors.As(err, &ve), ShouldBeTrue)
+		So(len(ve), ShouldEqual, 2)
+		So(ve[0].Name, ShouldEqual, "empty")
+		So(ve[0].Offset, ShouldEqual, 2)
+		So(ve[1].Name, ShouldEqual, "unset")
+		So(ve[1].Offset, ShouldEqual, 26)
+		So(err.Error(), ShouldEqual, "empty (offset 2): must be set; unset (offset 26): parameter null or not set")
+
+		_, err = ExpandVars("${unset:-${inner:?}}", vars)
+		So(err, ShouldNotBeNil)
+		So(err.(VarsError)[0].Offset, ShouldEqual, 9)
+
+		_, err = ExpandVars("${set:x}", vars)
+		So(errors.Is(err, ErrVarSyntax), ShouldBeTrue)
+		_, err = ExpandVars("${set:1:x}", vars)
+		So(errors.Is(err, ErrVarSyntax), ShouldBeTrue)
+		_, err = ExpandVars("${set:3:-3}", vars)
+		So(errors.Is(err, ErrVarSyntax), ShouldBeTrue)
+	})
+
+	Convey("VarsStrict", t, func() {
+		vars := map[string]string{"set": "value", "empty": ""}
+		expanded, err := VarsStrict("$set ${empty} ${unset:-ok}", vars)
+		So(err, ShouldBeNil)
+		So(expanded, ShouldEqual, "value  ok")
+
+		expanded, err = VarsStrict("$one and ${two} and ${#three} and ${set}", vars)
+		So(expanded, ShouldEqual, " and  and 0 and value")
+		So(errors.Is(err, ErrVarUndefined), ShouldBeTrue)
+		var ve VarsError
+		So(errors.As(err, &ve), ShouldBeTrue)
+		So(len(ve), ShouldEqual, 3)
+		So(ve[0].Name, ShouldEqual, "one")
+		So(ve[0].Offset, ShouldEqual, 0)
+		So(ve[1].Name, ShouldEqual, "two")
+		So(ve[1].Offset, ShouldEqual, 9)
+		So(ve[2].Name, ShouldEqual, "three")
+		So(ve[2].Offset, ShouldEqual, 20)
+		So(err.Error(), ShouldEqual, "one (offset 0): undefined variable; two (offset 9): undefined variable; three (offset 20): undefined variable")
+	})
+
+	Convey("VarsLenient", t, func() {
+		vars := map[string]string{"set": "value"}
+		So(VarsLenient("$set $unset ${unset} ${#unset} ${unset%.*}", vars), ShouldEqual, "value $unset ${unset} ${#unset} ${unset%.*}")
+		So(VarsLenient("${unset:-$set} ${unset:-$other}", vars), ShouldEqual, "value $other")
+		first := VarsLenient("$greeting, ${name}!", map[string]string{"greeting": "hello"})
+		So(first, ShouldEqual, "hello, ${name}!")
+		So(VarsLenient(first, map[string]string{"name": "world"}), ShouldEqual, "hello, world!")
+	})
+
+	Convey("Escape sequences", t, func() {
+		vars := map[string]string{"name": "value"}
+		dollar := VarsOptions{Escapes: EscapeDollar}
+		backslash := VarsOptions{Escapes: EscapeBackslash}
+		both := VarsOptions{Escapes: EscapeDollar | EscapeBackslash}
+		for _, test := range []struct {
+			input    string
+			options  VarsOptions
+			expected string
+		}{
+			{"$$name", VarsOptions{}, "$value"},
+			{`\$name`, VarsOptions{}, `\value`},
+			{"$$name", dollar, "$name"},
+			{"$${name}", dollar, "${name}"},
+			{"$$$name", dollar, "$value"},
+			{"$$$$", dollar, "$$"},
+			{"cost: 5$$", dollar, "cost: 5$"},
+			{`\$name`, dollar, `\value`},
+			{`\$name`, backslash, "$name"},
+			{`\${name}`, backslash, "${name}"},
+			{`\\$name`, backslash, `\value`},
+			{`\\\$name`, backslash, `\$name`},
+			{`a\\b\c`, backslash, `a\b\c`},
+			{`C:\path\$name`, backslash, `C:\path$name`},
+			{`trailing\`, backslash, `trailing\`},
+			{"$$name", backslash, "$value"},
+			{`$$name \$name $name`, both, "$name $name value"},
+			{"${unset:-$$}", dollar, "$"},
+			{`${unset:-\}}`, backslash, `\}`},
+			{`${unset:-\${name\}}`, backslash, `${name\}`},
+		} {
+			expanded, err := VarsWith(test.input, vars, test.options)
+			So(err, ShouldBeNil)
+			So(expanded, ShouldEqual, test.expected)
+		}
+	})
+	Convey("Name grammars", t, func() {
+		vars := map[string]string{
+			"HOME":    "/home/user",
+			"PATH":    "/bin",
+			"Name":    "upper",
+			"naïve":   "unicode",
+			"日本":      "japan",
+			"db.host": "localhost",
+			"db":      "database",
+		}
+		unicode := VarsOptions{Grammar: UnicodeVarsGrammar}
+		dotted := VarsOptions{Grammar: DottedVarsGrammar}
+		for _, test := range []struct {
+			input    string
+			options  VarsOptions
+			expected string
+		}{
+			{"$HOME/bin", VarsOptions{}, "/home/user/bin"},
+			{"${PATH}:/sbin", VarsOptions{}, "/bin:/sbin"},
+			{"$Name", VarsOptions{}, "upper"},
+			{"$1name", VarsOptions{}, "$1name"},
+			{"$naïve", VarsOptions{}, "ïve"},
+			{"$naïve", unicode, "unicode"},
+			{"${日本}", unicode, "japan"},
+			{"$HOME", unicode, "/home/user"},
+			{"${db.host}", VarsOptions{}, "${db.host}"},
+			{"${db.host}", dotted, "localhost"},
+			{"$db.host", dotted, "database.host"},
+			{"${db.port:-5432}", dotted, "5432"},
+			{"${#db.host}", dotted, "9"},
+		} {
+			expanded, err := VarsWith(test.input, vars, test.options)
+			So(err, ShouldBeNil)
+			So(expanded, ShouldEqual, test.expected)
+		}
+	})
+	Convey("Recursive expansion", t, func() {
+		recursive := VarsOptions{Recursive: true}
+		vars := map[string]string{
+			"scheme":       "https",
+			"host":         "${name}.example.com",
+			"name":         "www",
+			"base_url":     "${scheme}://${host}",
+			"api_url":      "$base_url/api",
+			"env":          "prod",
+			"prefix_prod":  "production",
+			"prefix_dev":   "development",
+			"db_prod_host": "db.$host",
+		}
+
+		expanded, err := VarsWith("$api_url", vars, VarsOptions{})
+		So(err, ShouldBeNil)
+		So(expanded, ShouldEqual, "$base_url/api")
+
+		expanded, err = VarsWith("$api_url", vars, recursive)
+		So(err, ShouldBeNil)
+		So(expanded, ShouldEqual, "https://www.example.com/api")
+
+		expanded, err = VarsWith("${prefix_${env}} ${db_${env}_host} ${#prefix_${env}}", vars, recursive)
+		So(err, ShouldBeNil)
+		So(expanded, ShouldEqual, "production db.www.example.com 10")
+
+		expanded, err = VarsWith("${prefix_${unset:-dev}:-none} ${prefix_${unset}:-none}", vars, recursive)
+		So(err, ShouldBeNil)
+		So(expanded, ShouldEqual, "development none")
+
+		expanded, err = VarsWith("${prefix_${env}}", vars, VarsOptions{})
+		So(err, ShouldBeNil)
+		So(expanded, ShouldEqual, "${prefix_prod}")
+
+		expanded, err = VarsWith("${prefix_${unknown}}", vars, VarsOptions{Recursive: true, Undefined: UndefinedKeep})
+		So(err, ShouldBeNil)
+		So(expanded, ShouldEqual, "${prefix_${unknown}}")
+	})
+
+	Convey("Recursive cycles", t, func() {
+		recursive := VarsOptions{Recursive: true}
+		vars := map[string]string{
+			"self": "[$self]",
+			"a":    "a:$b",
+			"b":    "b:${c}",
+			"c":    "c:$a",
+			"ok":   "fine",
+		}
+
+		expanded, err := VarsWith("$ok $self", vars, recursive)
+		So(expanded, ShouldEqual, "fine []")
+		So(errors.Is(err, ErrVarCycle), ShouldBeTrue)
+		So(err.Error(), ShouldEqual, "self (offset 4): self -> self")
+
+		expanded, err = VarsWith("x ${a} $a", vars, recursive)
+		So(expanded, ShouldEqual, "x a:b:c: a:b:c:")
+		So(err.Error(), ShouldEqual, "a (offset 2): a -> b -> c -> a")
+		var errs VarsError
+		So(errors.As(err, &errs), ShouldBeTrue)
+		So(errs, ShouldHaveLength, 1)
+
+		expanded, err = VarsWith("$b", vars, recursive)
+		So(expanded, ShouldEqual, "b:c:a:")
+		So(err.Error(), ShouldEqual, "b (offset 0): b -> c -> a -> b")
+	})
 }
+
+func tMakeVarsInput(size int) (input string, replacements map[string]string) {
+	replacements = map[string]string{
+		"name":    "strange new worlds",
+		"version": "v1.2.3",
+	}
+	var buffer strings.Builder
+	for buffer.Len() < size {
+		buffer.WriteString("image: registry/${name}:$version # ${unset:-default} costs $5\n")
+	}
+	input = buffer.String()[:size]
+	return
+}
+
+func benchmarkVars(b *testing.B, size int) {
+	input, replacements := tMakeVarsInput(size)
+	b.SetBytes(int64(size))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_ = Vars(input, replacements)
+	}
+}
+
+func BenchmarkVars1KB(b *testing.B)  { benchmarkVars(b, 1<<10) }
+func BenchmarkVars64KB(b *testing.B) { benchmarkVars(b, 64<<10) }
+func BenchmarkVars2MB(b *testing.B)  { benchmarkVars(b, 2<<20) }
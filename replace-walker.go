@@ -0,0 +1,223 @@
+// Copyright (c) 2024  The Go-Curses Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package replace
+
+import (
+	"io"
+	"io/fs"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/go-corelibs/path"
+)
+
+// walker is the filesystem abstraction used by the finders, allowing the same
+// finding logic to work with the local filesystem and any fs.FS
+type walker interface {
+	sample(name string, size int) (head []byte)
+	stat(name string) (info fs.FileInfo, err error)
+	lstat(name string) (info fs.FileInfo, err error)
+	listFiles(dir string, includeHidden bool) (files []string, err error)
+	listDirs(dir string, includeHidden bool) (dirs []string, err error)
+	open(name string) (fh fs.File, err error)
+	readFile(name string) (data []byte, err error)
+	mapFile(name string) (data []byte, release func(), err error)
+	identity(name string, info fs.FileInfo) (id cFileID, ok bool)
+}
+
+func newWalker(fsys fs.FS) (w walker) {
+	if fsys == nil {
+		w = cOsWalker{}
+		return
+	}
+	w = cFsWalker{fsys: fsys, links: make(map[string]fs.DirEntry)}
+	return
+}
+
+// cOsWalker is the local filesystem walker, using go-corelibs/path
+type cOsWalker struct{}
+
+// sample only reads regular files, opening anything else such as a named pipe
+// can block forever
+func (cOsWalker) sample(name string, size int) (head []byte) {
+	if info, err := os.Stat(name); size > 0 && err == nil && info.Mode().IsRegular() {
+		if fh, err := os.Open(name); err == nil {
+			defer fh.Close()
+			head = readSample(fh, size)
+		}
+	}
+	return
+}
+
+func (cOsWalker) stat(name string) (info fs.FileInfo, err error) {
+	info, err = os.Stat(name)
+	return
+}
+
+func (cOsWalker) lstat(name string) (info fs.FileInfo, err error) {
+	info, err = os.Lstat(name)
+	return
+}
+
+func (cOsWalker) listFiles(dir string, includeHidden bool) (files []string, err error) {
+	files, err = path.ListFiles(dir, includeHidden)
+	return
+}
+
+func (cOsWalker) listDirs(dir string, includeHidden bool) (dirs []string, err error) {
+	dirs, err = path.ListDirs(dir, includeHidden)
+	return
+}
+
+func (cOsWalker) open(name string) (fh fs.File, err error) {
+	fh, err = os.Open(name)
+	return
+}
+
+func (cOsWalker) readFile(name string) (data []byte, err error) {
+	data, err = os.ReadFile(name)
+	return
+}
+
+// mapFile memory maps the file where supported, falling back to reading the
+// whole file
+func (w cOsWalker) mapFile(name string) (data []byte, release func(), err error) {
+	if data, release, err = mmapFile(name); err != nil {
+		release = func() {}
+		data, err = w.readFile(name)
+	}
+	return
+}
+
+// identity falls back to pathIdentity where fileIdentity is not supported
+func (cOsWalker) identity(name string, info fs.FileInfo) (id cFileID, ok bool) {
+	if id, ok = fileIdentity(info); !ok {
+		id, ok = pathIdentity(name)
+	}
+	return
+}
+
+// cFsWalker is the fs.FS walker, all names are slash-separated and relative
+// to the root of the fs.FS
+type cFsWalker struct {
+	fsys  fs.FS
+	links map[string]fs.DirEntry // symlinks seen while listing directories
+}
+
+func (w cFsWalker) sample(name string, size int) (head []byte) {
+	if info, err := fs.Stat(w.fsys, name); size > 0 && err == nil && info.Mode().IsRegular() {
+		if fh, err := w.fsys.Open(name); err == nil {
+			defer fh.Close()
+			head = readSample(fh, size)
+		}
+	}
+	return
+}
+
+func (w cFsWalker) stat(name string) (info fs.FileInfo, err error) {
+	info, err = fs.Stat(w.fsys, name)
+	return
+}
+
+// lstat uses the directory entries of symlinks seen by list, because fs.FS
+// has no way to stat a symlink itself. Anything else is the same as stat
+func (w cFsWalker) lstat(name string) (info fs.FileInfo, err error) {
+	if entry, present := w.links[name]; present {
+		info, err = entry.Info()
+		return
+	}
+	info, err = fs.Stat(w.fsys, name)
+	return
+}
+
+func (w cFsWalker) list(dir string, includeHidden, dirs bool) (paths []string, err error) {
+	var entries []fs.DirEntry
+	if entries, err = fs.ReadDir(w.fsys, dir); err != nil {
+		return
+	}
+	for _, entry := range entries {
+		if entry.IsDir() != dirs {
+			continue
+		} else if !includeHidden && path.IsHidden(entry.Name()) {
+			continue
+		}
+		name := fsJoin(dir, entry.Name())
+		if entry.Type()&fs.ModeSymlink != 0 {
+			w.links[name] = entry
+		}
+		paths = append(paths, name)
+	}
+	// hidden things first, like go-corelibs/path does
+	sort.SliceStable(paths, func(i, j int) (less bool) {
+		less = path.IsHidden(paths[i]) && !path.IsHidden(paths[j])
+		return
+	})
+	return
+}
+
+func (w cFsWalker) listFiles(dir string, includeHidden bool) (files []string, err error) {
+	files, err = w.list(dir, includeHidden, false)
+	return
+}
+
+func (w cFsWalker) listDirs(dir string, includeHidden bool) (dirs []string, err error) {
+	dirs, err = w.list(dir, includeHidden, true)
+	return
+}
+
+func (w cFsWalker) open(name string) (fh fs.File, err error) {
+	fh, err = w.fsys.Open(name)
+	return
+}
+
+func (w cFsWalker) readFile(name string) (data []byte, err error) {
+	data, err = fs.ReadFile(w.fsys, name)
+	return
+}
+
+// mapFile is the same as readFile because fs.FS has no concept of memory
+// mapping
+func (w cFsWalker) mapFile(name string) (data []byte, release func(), err error) {
+	release = func() {}
+	data, err = w.readFile(name)
+	return
+}
+
+// identity only uses fileIdentity because fs.FS names can't be resolved to
+// local paths
+func (w cFsWalker) identity(_ string, info fs.FileInfo) (id cFileID, ok bool) {
+	id, ok = fileIdentity(info)
+	return
+}
+
+// fsJoin joins fs.FS names, which are always slash-separated and never start
+// with "./"
+func fsJoin(dir, name string) (joined string) {
+	if dir == "." || dir == "" {
+		joined = name
+		return
+	}
+	joined = strings.TrimSuffix(dir, "/") + "/" + name
+	return
+}
+
+// readSample returns up to `size` leading bytes read from `r`
+func readSample(r io.Reader, size int) (head []byte) {
+	head = make([]byte, size)
+	n, _ := io.ReadFull(r, head)
+	head = head[:n]
+	return
+}
@@ -0,0 +1,53 @@
+// Copyright (c) 2024  The Go-Curses Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build unix
+
+package replace
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestWalkerFifo(t *testing.T) {
+	Convey("sample skips named pipes", t, func() {
+		dir := tMakeTree(t, "a.txt")
+		fifo := tMakeFifo(t, dir)
+
+		for name, test := range map[string]struct {
+			w          walker
+			fifo, file string
+		}{
+			"os": {cOsWalker{}, fifo, filepath.Join(dir, "a.txt")},
+			"fs": {newWalker(os.DirFS(dir)), "fifo.txt", "a.txt"},
+		} {
+			done := make(chan []byte, 1)
+			go func() {
+				done <- test.w.sample(test.fifo, 8)
+			}()
+			select {
+			case head := <-done:
+				So(head, ShouldBeEmpty)
+			case <-time.After(5 * time.Second):
+				So(name+" walker blocked on the named pipe", ShouldBeEmpty)
+			}
+			So(string(test.w.sample(test.file, 3)), ShouldEqual, "a.t")
+		}
+	})
+}